@@ -3,21 +3,31 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	controller "github.com/stolostron/clusterclaims-controller/controllers/clusterpools"
-	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
 	mcv1 "open-cluster-management.io/api/cluster/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -36,11 +46,50 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
 	var enableLeaderElection bool
 	var leaderElectionLeaseDuration time.Duration
 	var leaderElectionRenewDeadline time.Duration
 	var leaderElectionRetryPeriod time.Duration
+	var namespaceLabel string
+	var namespaceLabelValue string
+	var concurrency int
+	var finalizerName string
+	var enableWebhooks bool
+	var webhookPort int
+	var webhookCertDir string
+	var watchLabelSelector string
+	var autoLabelNamespace bool
+	var namespaceDeletionGracePeriod time.Duration
+	var ownerRefMode bool
+	var batchDelete bool
+	var watchNamespace string
+	var crossNamespaceRefCounting bool
+	var managedSecretLabels string
+	var refCacheTTL time.Duration
+	var enableOrphanSweep bool
+	var cleanupScope string
+	var auditConfigMap string
+	var protectedNamespaces string
+	var listOrphaned bool
+	var extraSecretRefPaths string
+	var checkNamespaceEmpty bool
+	var annotateLastCleanup bool
+	var requireManagedLabel bool
+	var logLevel string
+	var manageFinalizer bool
+	var manageNamespaceFinalizer bool
+	var deleteRetries int
+	var clientTimeout time.Duration
+	var minPoolAgeForCleanup time.Duration
+	var cleanupTimeBudget time.Duration
+	var waitForClusterDeployments bool
+	var resyncInterval time.Duration
+	var orphanSweepInterval time.Duration
+	var orphanMetricsInterval time.Duration
+	var namespaceDeletePropagation string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8383", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-addr", ":8384", "The address the health and readiness probe endpoints bind to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -69,10 +118,83 @@ func main() {
 		"The duration the clients should wait between attempting acquisition and renewal "+
 			"of a leadership. This is only applicable if leader election is enabled.",
 	)
+	flag.StringVar(&namespaceLabel, "namespace-label", controller.LABEL_NAMESPACE,
+		"The label key that marks a namespace for deletion when its last cluster pool is removed.")
+	flag.StringVar(&namespaceLabelValue, "namespace-label-value", controller.CLUSTERPOOLS,
+		"The value of the namespace-label that marks a namespace for deletion.")
+	flag.IntVar(&concurrency, "concurrency", 1,
+		"The number of cluster pools reconciled in parallel.")
+	flag.StringVar(&finalizerName, "finalizer-name", controller.FINALIZER,
+		"The finalizer added to cluster pools. Each controller instance on a cluster needs its own finalizer name.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the ClusterPool and Namespace validating webhooks. Requires serving certificates and a ValidatingWebhookConfiguration, see deploy/webhook.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443,
+		"The port the webhook server binds to, with -enable-webhooks.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"The directory holding the tls.crt and tls.key serving certificates of the webhook server, with -enable-webhooks.")
+	flag.StringVar(&watchLabelSelector, "watch-label-selector", "",
+		"Only reconcile cluster pools matching this label selector. All cluster pools are reconciled when empty.")
+	flag.BoolVar(&autoLabelNamespace, "auto-label-namespace", false,
+		"Add the namespace-label to an otherwise empty namespace when its first cluster pool is created, so the namespace is deleted with its last cluster pool.")
+	flag.DurationVar(&namespaceDeletionGracePeriod, "namespace-deletion-grace-period", 0,
+		"How long the last cluster pool is held before its namespace is deleted. A cluster pool created in the namespace meanwhile spares it.")
+	flag.StringVar(&namespaceDeletePropagation, "namespace-delete-propagation", "",
+		"The propagation policy of the namespace deletion: Foreground, Background or Orphan. The API server default when empty.")
+	flag.BoolVar(&checkNamespaceEmpty, "check-namespace-empty-before-delete", false,
+		"Keep the namespace of the last cluster pool while it still holds pods or persistent volume claims, even when it has the namespace-label.")
+	flag.StringVar(&protectedNamespaces, "protected-namespaces", "",
+		"Comma separated glob patterns of namespaces that are never deleted, whatever their labels. kube-* and openshift-* are always protected.")
+	flag.StringVar(&extraSecretRefPaths, "extra-secret-ref-paths", "",
+		"Comma separated, dot separated field paths into the cluster pool naming more secrets to clean up with it, like metadata.annotations.release-image-secret.")
+	flag.BoolVar(&listOrphaned, "list-orphaned-secrets", false,
+		"Print the labeled secrets of labeled namespaces that no cluster pool references, and exit without deleting anything.")
+	flag.BoolVar(&ownerRefMode, "owner-ref-mode", false,
+		"Make cluster pools owners of the secrets they reference and leave secret cleanup to the garbage collector.")
+	flag.StringVar(&watchNamespace, "namespace", "",
+		"Only reconcile cluster pools in this namespace and never touch resources outside it. All namespaces when empty.")
+	flag.BoolVar(&crossNamespaceRefCounting, "cross-namespace-ref-counting", false,
+		"Keep the secrets of a deleted cluster pool while a cluster pool in any namespace references a secret of the same name. Lists every cluster pool on each delete.")
+	flag.StringVar(&managedSecretLabels, "managed-secret-labels", "",
+		"Comma separated key=value labels of auxiliary secrets deleted with the namespace of the last cluster pool, unless a cluster pool references them.")
+	flag.DurationVar(&refCacheTTL, "ref-cache-ttl", 0,
+		"Reuse the cluster pool list of a namespace for this long while its pools are deleted, instead of listing the pools for every deletion. Disabled when zero.")
+	flag.StringVar(&cleanupScope, "cleanup-scope", string(controller.CLEANUP_SCOPE_ALL),
+		"What is deleted with a cluster pool: All, ProviderOnly (only provider credential and certificates secrets) or None.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 0,
+		"Reconcile every cluster pool again at this interval, with jitter, so missed cleanups are retried. Disabled when zero.")
+	flag.BoolVar(&manageFinalizer, "manage-finalizer", true,
+		"Add the cleanup finalizer to cluster pools. When false, cleanup runs from the delete event and may race a fast delete.")
+	flag.BoolVar(&manageNamespaceFinalizer, "manage-namespace-finalizer", false,
+		"Add the finalizer to labeled namespaces too, and clean up their cluster pools when the namespace is deleted first.")
+	flag.IntVar(&deleteRetries, "delete-retries", 0,
+		"How many times a secret or namespace deletion failing with a transient error is retried, with backoff, before the cleanup is requeued.")
+	flag.DurationVar(&clientTimeout, "client-timeout", controller.CLIENT_TIMEOUT,
+		"How long the API calls of each cleanup and finalizer step may take before the step fails and is retried.")
+	flag.BoolVar(&waitForClusterDeployments, "wait-for-cluster-deployments", false,
+		"Keep the provider secret of a deleted cluster pool, and its namespace, while cluster deployments in the namespace still reference the secret to deprovision.")
+	flag.DurationVar(&cleanupTimeBudget, "cleanup-time-budget", 0,
+		"Stop a cleanup pass once a secret step ends past this budget and continue it in a later pass, keeping the finalizer until it is done. No limit when zero.")
+	flag.DurationVar(&minPoolAgeForCleanup, "min-pool-age-for-cleanup", 0,
+		"Skip the cleanup of cluster pools deleted before they reached this age, their finalizer is still removed. Disabled when zero.")
+	flag.StringVar(&logLevel, "log-level", "info",
+		"The log level: debug, info, warn or error. Unknown levels log at info.")
+	flag.BoolVar(&requireManagedLabel, "require-managed-label", false,
+		"Only delete referenced secrets carrying the namespace-label key or the "+controller.MANAGED+"=true annotation.")
+	flag.StringVar(&auditConfigMap, "audit-config-map", "",
+		"The namespace/name of a config map recording every resource deleted with a cluster pool. No audit is recorded when empty.")
+	flag.BoolVar(&annotateLastCleanup, "annotate-last-cleanup", false,
+		"Record the last cluster pool cleanup that kept its namespace, and the secrets it deleted, in an annotation on the namespace.")
+	flag.BoolVar(&enableOrphanSweep, "enable-orphan-sweep", false,
+		"Periodically delete the labeled secrets of labeled namespaces that no cluster pool references.")
+	flag.DurationVar(&orphanSweepInterval, "orphan-sweep-interval", controller.ORPHAN_SWEEP_INTERVAL,
+		"How often the orphan sweep runs. Secrets younger than this are never swept.")
+	flag.DurationVar(&orphanMetricsInterval, "orphan-metrics-interval", 0,
+		"Count the orphaned secrets for the clusterpools_orphaned_secrets metric at this interval, without deleting them. Disabled when zero.")
+	flag.BoolVar(&batchDelete, "batch-delete", false,
+		"Delete the labeled secrets of a namespace with a single DeleteCollection when its last cluster pool is removed.")
 	flag.Parse()
 
-	// To run in debug change zapcore.InfoLevel to zapcore.DebugLevel
-	ctrl.SetLogger(zap.New(zap.Level(zapcore.InfoLevel)))
+	ctrl.SetLogger(controller.NewLogger(controller.ParseLogLevel(logLevel)))
 
 	setupLog.Info("Leader election settings", "enableLeaderElection", enableLeaderElection,
 		"leaseDuration", leaderElectionLeaseDuration,
@@ -90,31 +212,154 @@ func main() {
 		setupLog.Error(err, "failed to create kube client")
 		os.Exit(1)
 	}
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
+	selector, err := labels.Parse(watchLabelSelector)
+	if err != nil {
+		setupLog.Error(err, "invalid watch label selector")
+		os.Exit(1)
+	}
+	if selector.Empty() {
+		selector = nil
+	}
+
+	managedLabels, err := labels.ConvertSelectorToLabelsMap(managedSecretLabels)
+	if err != nil {
+		setupLog.Error(err, "invalid managed secret labels")
+		os.Exit(1)
+	}
+
+	propagation := metav1.DeletionPropagation(namespaceDeletePropagation)
+	if propagation != "" && propagation != metav1.DeletePropagationForeground &&
+		propagation != metav1.DeletePropagationBackground && propagation != metav1.DeletePropagationOrphan {
+		setupLog.Error(fmt.Errorf("unknown propagation policy %q", namespaceDeletePropagation), "invalid namespace delete propagation")
+		os.Exit(1)
+	}
+	var protected []string
+	for _, pattern := range strings.Split(protectedNamespaces, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			setupLog.Error(fmt.Errorf("%w: %q", err, pattern), "invalid protected namespaces")
+			os.Exit(1)
+		}
+		protected = append(protected, pattern)
+	}
+	var extraPaths []string
+	for _, fieldPath := range strings.Split(extraSecretRefPaths, ",") {
+		if fieldPath = strings.TrimSpace(fieldPath); fieldPath != "" {
+			extraPaths = append(extraPaths, fieldPath)
+		}
+	}
+	scope := controller.CleanupScope(cleanupScope)
+	if scope != controller.CLEANUP_SCOPE_ALL && scope != controller.CLEANUP_SCOPE_PROVIDER_ONLY && scope != controller.CLEANUP_SCOPE_NONE {
+		setupLog.Error(fmt.Errorf("unknown cleanup scope %q", cleanupScope), "invalid cleanup scope")
+		os.Exit(1)
+	}
+
+	var auditKey types.NamespacedName
+	if auditConfigMap != "" {
+		namespace, name, found := strings.Cut(auditConfigMap, "/")
+		if !found || namespace == "" || name == "" {
+			setupLog.Error(fmt.Errorf("expected namespace/name, got %q", auditConfigMap), "invalid audit config map")
+			os.Exit(1)
+		}
+		auditKey = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
+	disableCleanup, _ := strconv.ParseBool(os.Getenv(controller.DISABLE_CLEANUP_ENV))
+	if disableCleanup {
+		setupLog.Info("Cleanup is globally disabled, no secrets or namespaces will be deleted", "env", controller.DISABLE_CLEANUP_ENV)
+	}
+
+	reconciler := &controller.ClusterPoolsReconciler{
+		KubeClient: kubeClient,
+		Log:        ctrl.Log.WithName("controller").WithName("ClusterPoolsReconciler"),
+
+		NamespaceLabel:      namespaceLabel,
+		NamespaceLabelValue: namespaceLabelValue,
+		Concurrency:         concurrency,
+		FinalizerName:       finalizerName,
+		LeaderElection:      enableLeaderElection,
+		WatchLabelSelector:  selector,
+		AutoLabelNamespace:  autoLabelNamespace,
+
+		NamespaceDeletionGracePeriod: namespaceDeletionGracePeriod,
+		NamespaceDeletePropagation:   propagation,
+		ProtectedNamespaces:          protected,
+		ExtraSecretRefPaths:          extraPaths,
+		OwnerRefMode:                 ownerRefMode,
+		BatchDelete:                  batchDelete,
+		DisableCleanup:               disableCleanup,
+		Namespace:                    watchNamespace,
+		CrossNamespaceRefCounting:    crossNamespaceRefCounting,
+		ManagedSecretLabels:          managedLabels,
+		RefCacheTTL:                  refCacheTTL,
+		EnableOrphanSweep:            enableOrphanSweep,
+		CleanupScope:                 scope,
+		AuditConfigMap:               auditKey,
+		AnnotateLastCleanup:          annotateLastCleanup,
+		RequireManagedLabel:          requireManagedLabel,
+		DisableFinalizer:             !manageFinalizer,
+		ManageNamespaceFinalizer:     manageNamespaceFinalizer,
+		DeleteRetries:                deleteRetries,
+		ClientTimeout:                clientTimeout,
+		MinPoolAgeForCleanup:         minPoolAgeForCleanup,
+		CleanupTimeBudget:            cleanupTimeBudget,
+		WaitForClusterDeployments:    waitForClusterDeployments,
+		ResyncInterval:               resyncInterval,
+		OrphanSweepInterval:          orphanSweepInterval,
+		OrphanMetricsInterval:        orphanMetricsInterval,
+
+		CheckNamespaceEmptyBeforeDelete: checkNamespaceEmpty,
+	}
+
+	if listOrphaned {
+		listOrphanedSecrets(cfg, reconciler)
+		return
+	}
+
+	options := ctrl.Options{
+		Scheme: scheme,
 		Metrics: server.Options{
 			BindAddress: metricsAddr,
 		},
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "clusterpools-controller.open-cluster-management.io",
-		LeaseDuration:      &leaderElectionLeaseDuration,
-		RenewDeadline:      &leaderElectionRenewDeadline,
-		RetryPeriod:        &leaderElectionRetryPeriod,
-	})
+		HealthProbeBindAddress: probeAddr,
+		LeaseDuration:          &leaderElectionLeaseDuration,
+		RenewDeadline:          &leaderElectionRenewDeadline,
+		RetryPeriod:            &leaderElectionRetryPeriod,
+	}
+	if enableWebhooks {
+		options.WebhookServer = webhook.NewServer(webhook.Options{
+			Port:    webhookPort,
+			CertDir: webhookCertDir,
+		})
+	}
+	reconciler.ApplyLeaderElection(&options)
+	reconciler.ApplyNamespace(&options)
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controller.ClusterPoolsReconciler{
-		KubeClient: kubeClient,
-		Client:     mgr.GetClient(),
-		Log:        ctrl.Log.WithName("controller").WithName("ClusterPoolsReconciler"),
-		Scheme:     mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	reconciler.Client = mgr.GetClient()
+	reconciler.Scheme = mgr.GetScheme()
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller")
 		os.Exit(1)
 	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("hive-crds", controller.HiveCRDReadyCheck(mgr.GetClient())); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		reconciler.SetupWebhookWithManager(mgr)
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -124,3 +369,28 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// listOrphanedSecrets prints the labeled secrets no cluster pool references, as the configured reconciler finds
+// them, without deleting anything
+func listOrphanedSecrets(cfg *rest.Config, reconciler *controller.ClusterPoolsReconciler) {
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "failed to create client")
+		os.Exit(1)
+	}
+	orphaned, err := controller.FindOrphanedSecrets(context.Background(), c, controller.OrphanedSecretOptions{
+		NamespaceLabel:            reconciler.NamespaceLabel,
+		NamespaceLabelValue:       reconciler.NamespaceLabelValue,
+		Namespace:                 reconciler.Namespace,
+		SecretNameResolver:        reconciler.SecretNameResolver,
+		ExtraSecretRefPaths:       reconciler.ExtraSecretRefPaths,
+		CrossNamespaceRefCounting: reconciler.CrossNamespaceRefCounting,
+	})
+	if err != nil {
+		setupLog.Error(err, "failed to find orphaned secrets")
+		os.Exit(1)
+	}
+	for _, secret := range orphaned {
+		fmt.Println(secret.String())
+	}
+}
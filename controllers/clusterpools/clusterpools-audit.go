@@ -0,0 +1,121 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// AUDIT_LOG_KEY is the AuditConfigMap data key holding the audit entries, one per line
+const AUDIT_LOG_KEY = "audit.log"
+
+// AUDIT_MAX_ENTRIES caps the entries kept in the AuditConfigMap, the oldest are dropped first
+const AUDIT_MAX_ENTRIES = 1000
+
+// LAST_CLEANUP is the namespace annotation AnnotateLastCleanup records the last cluster pool cleanup in
+const LAST_CLEANUP = "clusterpools-controller.open-cluster-management.io/last-cleanup"
+
+// lastCleanup is the LAST_CLEANUP annotation value
+type lastCleanup struct {
+	ClusterPool string   `json:"clusterPool"`
+	Time        string   `json:"time"`
+	Secrets     []string `json:"secrets"`
+}
+
+// recordAudit appends a "<timestamp> <namespace>/<cluster pool> <resource>" entry per deleted resource to the
+// AuditConfigMap, creating it when missing. Concurrent writers are retried on conflict.
+func recordAudit(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool, deleted []string) error {
+	if r.AuditConfigMap.Name == "" || len(deleted) == 0 {
+		return nil
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	var entries []string
+	for _, resource := range deleted {
+		entries = append(entries, timestamp+" "+cp.Namespace+"/"+cp.Name+" "+resource)
+	}
+
+	configMaps := r.KubeClient.CoreV1().ConfigMaps(r.AuditConfigMap.Namespace)
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err)
+	}, func() error {
+		cm, err := configMaps.Get(ctx, r.AuditConfigMap.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:      r.AuditConfigMap.Name,
+				Namespace: r.AuditConfigMap.Namespace,
+			}}
+			cm.Data = map[string]string{AUDIT_LOG_KEY: appendAuditEntries("", entries)}
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[AUDIT_LOG_KEY] = appendAuditEntries(cm.Data[AUDIT_LOG_KEY], entries)
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// appendAuditEntries appends the entries to the audit log, keeping the newest AUDIT_MAX_ENTRIES
+func appendAuditEntries(auditLog string, entries []string) string {
+	var lines []string
+	if auditLog != "" {
+		lines = strings.Split(strings.TrimSuffix(auditLog, "\n"), "\n")
+	}
+	lines = append(lines, entries...)
+	if len(lines) > AUDIT_MAX_ENTRIES {
+		lines = lines[len(lines)-AUDIT_MAX_ENTRIES:]
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// annotateLastCleanup records the cluster pool and the secrets its cleanup deleted in the LAST_CLEANUP annotation
+// of its namespace, replacing the previous cleanup. Nothing is recorded when the cleanup deleted the namespace.
+func annotateLastCleanup(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool, deleted []string) error {
+	if !r.AnnotateLastCleanup || len(deleted) == 0 {
+		return nil
+	}
+
+	cleanup := lastCleanup{ClusterPool: cp.Name, Time: time.Now().UTC().Format(time.RFC3339), Secrets: []string{}}
+	for _, resource := range deleted {
+		if strings.HasPrefix(resource, "namespace/") {
+			return nil
+		}
+		cleanup.Secrets = append(cleanup.Secrets, strings.TrimPrefix(resource, "secret/"))
+	}
+	value, err := json.Marshal(cleanup)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{LAST_CLEANUP: string(value)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.KubeClient.CoreV1().Namespaces().Patch(ctx, cp.Namespace, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func getAuditEntries(ctx context.Context, cpr *ClusterPoolsReconciler) []string {
+	cm, err := cpr.KubeClient.CoreV1().ConfigMaps(cpr.AuditConfigMap.Namespace).Get(ctx, cpr.AuditConfigMap.Name, v1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(cm.Data[AUDIT_LOG_KEY], "\n"), "\n")
+}
+
+func TestReconcileClusterPoolDeleteAudit(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.AuditConfigMap = types.NamespacedName{Namespace: "audit", Name: "clusterpools-audit"}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool was cleaned up")
+
+	entries := getAuditEntries(ctx, cpr)
+	assert.Len(t, entries, 4, "an entry per deleted resource")
+	for i, resource := range []string{"secret/secret02", "secret/secret01", "secret/secret03", "namespace/" + CP_NAMESPACE} {
+		assert.True(t, strings.HasSuffix(entries[i], " "+CP_NAMESPACE+"/"+CP_NAME+" "+resource),
+			"the entry names the cluster pool and the deleted resource: "+entries[i])
+	}
+}
+
+func TestRecordAuditAppends(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.AuditConfigMap = types.NamespacedName{Namespace: "audit", Name: "clusterpools-audit"}
+	cpr.KubeClient.CoreV1().ConfigMaps("audit").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "clusterpools-audit", Namespace: "audit"},
+		Data:       map[string]string{AUDIT_LOG_KEY: "2024-01-01T00:00:00Z tenant02/pool02 secret/old\n"},
+	}, v1.CreateOptions{})
+
+	err := recordAudit(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), []string{"secret/secret01"})
+
+	assert.Nil(t, err, "nil, when the entry was appended")
+	entries := getAuditEntries(ctx, cpr)
+	assert.Len(t, entries, 2, "the entry is appended to the existing entries")
+	assert.Equal(t, "2024-01-01T00:00:00Z tenant02/pool02 secret/old", entries[0], "existing entries are kept")
+	assert.True(t, strings.HasSuffix(entries[1], " "+CP_NAMESPACE+"/"+CP_NAME+" secret/secret01"), "the new entry is last")
+}
+
+func TestRecordAuditConflict(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.AuditConfigMap = types.NamespacedName{Namespace: "audit", Name: "clusterpools-audit"}
+	recordAudit(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), []string{"secret/secret01"})
+
+	// Another controller instance updates the config map first
+	conflicts := 1
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("update", "configmaps",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if conflicts > 0 {
+				conflicts--
+				return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "clusterpools-audit", nil)
+			}
+			return false, nil, nil
+		})
+
+	err := recordAudit(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), []string{"secret/secret02"})
+
+	assert.Nil(t, err, "nil, when the update was retried after the conflict")
+	assert.Len(t, getAuditEntries(ctx, cpr), 2, "the entry is appended after the conflict")
+}
+
+func TestRecordAuditDisabled(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	err := recordAudit(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), []string{"secret/secret01"})
+
+	assert.Nil(t, err, "nil, when no audit config map is set")
+	configMaps, _ := cpr.KubeClient.CoreV1().ConfigMaps("").List(ctx, v1.ListOptions{})
+	assert.Empty(t, configMaps.Items, "no config map is created without an AuditConfigMap")
+}
+
+func TestAppendAuditEntriesRotates(t *testing.T) {
+
+	var auditLog string
+	for i := 0; i < AUDIT_MAX_ENTRIES; i++ {
+		auditLog = appendAuditEntries(auditLog, []string{"entry" + strconv.Itoa(i)})
+	}
+	auditLog = appendAuditEntries(auditLog, []string{"newest"})
+
+	lines := strings.Split(strings.TrimSuffix(auditLog, "\n"), "\n")
+	assert.Len(t, lines, AUDIT_MAX_ENTRIES, "the audit log is capped")
+	assert.Equal(t, "entry1", lines[0], "the oldest entry is dropped")
+	assert.Equal(t, "newest", lines[AUDIT_MAX_ENTRIES-1], "the newest entry is kept")
+}
+
+func getLastCleanup(ctx context.Context, cpr *ClusterPoolsReconciler) *lastCleanup {
+	ns, err := cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	if err != nil || ns.Annotations[LAST_CLEANUP] == "" {
+		return nil
+	}
+	var cleanup lastCleanup
+	if err := json.Unmarshal([]byte(ns.Annotations[LAST_CLEANUP]), &cleanup); err != nil {
+		return nil
+	}
+	return &cleanup
+}
+
+func TestReconcileClusterPoolDeleteAnnotateLastCleanup(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.AnnotateLastCleanup = true
+
+	// The sibling keeps the namespace and shares the install-config secret
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.PullSecretRef.Name = "secret11"
+	sibling.Spec.Platform.GCP.CredentialsSecretRef.Name = "secret13"
+	cpr.Client.Create(ctx, sibling)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool was cleaned up")
+
+	cleanup := getLastCleanup(ctx, cpr)
+	if assert.NotNil(t, cleanup, "the namespace is annotated with the cleanup") {
+		assert.Equal(t, CP_NAME, cleanup.ClusterPool, "the annotation names the cluster pool")
+		assert.Equal(t, []string{"secret01", "secret03"}, cleanup.Secrets, "the annotation lists the deleted secrets")
+		assert.NotEmpty(t, cleanup.Time, "the annotation has the cleanup time")
+	}
+}
+
+func TestAnnotateLastCleanupSkipped(t *testing.T) {
+
+	ctx := context.Background()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	for _, enabled := range []bool{false, true} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.AnnotateLastCleanup = enabled
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), v1.CreateOptions{})
+
+		deleted := []string{"secret/secret01"}
+		if enabled {
+			deleted = append(deleted, "namespace/"+CP_NAMESPACE)
+		}
+		assert.Nil(t, annotateLastCleanup(ctx, cpr, cp, deleted), "nil, when nothing is annotated")
+		assert.Nil(t, getLastCleanup(ctx, cpr), "no annotation when disabled or when the namespace was deleted, enabled "+strconv.FormatBool(enabled))
+	}
+}
@@ -4,214 +4,1848 @@ package clusterpools
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	stderrors "errors"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const DEBUG = 1
 const INFO = 0
 const WARN = -1
 const ERROR = -2
+const LEADER_ELECTION_ID = "clusterpools-controller.open-cluster-management.io"
 const FINALIZER = "clusterpools-controller.open-cluster-management.io/cleanup"
 
+// CP_TYPE_NONE is the platform of cluster pools without a cloud provider
+const CP_TYPE_NONE = "none"
+
 const LABEL_NAMESPACE = "open-cluster-management.io/managed-by"
 const CLUSTERPOOLS = "clusterpools"
 
+const SECRET_TYPE_PULL = "pull"
+const SECRET_TYPE_INSTALLCONFIG = "installconfig"
+const SECRET_TYPE_PROVIDER = "provider"
+const SECRET_TYPE_CERTIFICATES = "certificates"
+const SECRET_TYPE_SSH = "ssh"
+const SECRET_TYPE_CLUSTERDEPLOYMENT = "clusterdeployment"
+const SECRET_TYPE_LABELED = "labeled"
+const SECRET_TYPE_EXTRA = "extra"
+
+var secretTypeDescriptions = map[string]string{
+	SECRET_TYPE_PULL:              "pull",
+	SECRET_TYPE_INSTALLCONFIG:     "install-config",
+	SECRET_TYPE_PROVIDER:          "provider credential",
+	SECRET_TYPE_CERTIFICATES:      "certificates",
+	SECRET_TYPE_SSH:               "SSH private key",
+	SECRET_TYPE_CLUSTERDEPLOYMENT: "cluster deployment",
+	SECRET_TYPE_LABELED:           "labeled",
+	SECRET_TYPE_EXTRA:             "extra",
+}
+
+// RETAIN_NAMESPACE set to "true" on a cluster pool or its namespace keeps the namespace when the last pool is removed
+const RETAIN_NAMESPACE = "clusterpools-controller.open-cluster-management.io/retain-namespace"
+
+// MANAGED set to "true" on a secret marks it as managed by clusterpools, like the namespace label key, for RequireManagedLabel
+const MANAGED = "clusterpools-controller.open-cluster-management.io/managed"
+
+// RETAIN_SECRETS is a comma separated list of secret types (pull, installconfig, provider) a cluster pool never deletes
+const RETAIN_SECRETS = "clusterpools-controller.open-cluster-management.io/retain-secrets"
+
+// PAUSED set to "true" on a cluster pool stops all reconciliation of the pool, including its finalizer
+const PAUSED = "clusterpools-controller.open-cluster-management.io/paused"
+
+// CLEANUP_COMPLETED_AT is set to the RFC3339 time of the completed cleanup in the patch removing the finalizer,
+// so observers of the cluster pool see the cleanup is done before the pool is gone
+const CLEANUP_COMPLETED_AT = "clusterpools-controller.open-cluster-management.io/cleanup-completed-at"
+
+// CleanupScope selects the categories of resources deleteResources deletes
+type CleanupScope string
+
+// CLEANUP_SCOPE_ALL deletes the secrets of the cluster pool, its cluster deployments' secrets and the namespace
+const CLEANUP_SCOPE_ALL CleanupScope = "All"
+
+// CLEANUP_SCOPE_PROVIDER_ONLY deletes only the provider credential and certificates secrets of the cluster pool
+const CLEANUP_SCOPE_PROVIDER_ONLY CleanupScope = "ProviderOnly"
+
+// CLEANUP_SCOPE_NONE deletes nothing, the finalizer is still removed
+const CLEANUP_SCOPE_NONE CleanupScope = "None"
+
+// ORPHAN_SWEEP_INTERVAL is how often EnableOrphanSweep looks for orphaned secrets, when OrphanSweepInterval is not set
+const ORPHAN_SWEEP_INTERVAL = 10 * time.Minute
+
+// CLAIMS_REQUEUE is how often a deleted cluster pool checks whether its cluster claims have been released
+const CLAIMS_REQUEUE = 30 * time.Second
+
+// DEPROVISION_REQUEUE is how often a deleted cluster pool checks, with WaitForClusterDeployments, whether the
+// cluster deployments using its provider secret are gone
+const DEPROVISION_REQUEUE = 30 * time.Second
+
+// CLEANUP_BUDGET_REQUEUE is how soon a cleanup that spent its CleanupTimeBudget continues
+const CLEANUP_BUDGET_REQUEUE = time.Second
+
+// DISABLE_CLEANUP_ENV set to "true" in the controller's environment turns off all secret and namespace deletion,
+// see ClusterPoolsReconciler.DisableCleanup
+const DISABLE_CLEANUP_ENV = "CLUSTERPOOLS_DISABLE_CLEANUP"
+
+// CLIENT_TIMEOUT bounds the API calls of a reconcile step, when ClientTimeout is not set
+const CLIENT_TIMEOUT = 30 * time.Second
+
+const BACKOFF_BASE_DELAY = time.Second
+const BACKOFF_MAX_DELAY = 5 * time.Minute
+
+// BACKOFF_JITTER_FACTOR spreads the requeue of conflicting pools over up to half the backoff delay again
+const BACKOFF_JITTER_FACTOR = 0.5
+
+const CONDITION_CLEANUP_COMPLETED hivev1.ClusterPoolConditionType = "CleanupCompleted"
+const CONDITION_CLEANUP_FAILED hivev1.ClusterPoolConditionType = "CleanupFailed"
+
+// CONDITION_MISSING_SECRET is true while secrets the cluster pool references do not exist in its namespace
+const CONDITION_MISSING_SECRET hivev1.ClusterPoolConditionType = "MissingSecret"
+
+// MISSING_SECRET_REQUEUE is how often a cluster pool with missing secrets checks whether they were created
+const MISSING_SECRET_REQUEUE = time.Minute
+
+const REASON_SECRET_DELETED = "SecretDeleted"
+const REASON_NAMESPACE_DELETED = "NamespaceDeleted"
+const REASON_NAMESPACE_RETAINED = "NamespaceRetained"
+const REASON_FINALIZER_REMOVAL_FAILED = "FinalizerRemovalFailed"
+
 // ClusterPoolsReconciler reconciles a ClusterPool, mainly for the delete
 type ClusterPoolsReconciler struct {
 	KubeClient kubernetes.Interface
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// NamespaceLabel and NamespaceLabelValue mark namespaces that are deleted with their last cluster pool,
+	// defaulting to LABEL_NAMESPACE and CLUSTERPOOLS
+	NamespaceLabel      string
+	NamespaceLabelValue string
+
+	// Namespace scopes the controller to a single namespace, all namespaces when empty. Pools, cluster
+	// deployments and reference counting never reach outside it, and ApplyNamespace scopes the manager cache.
+	Namespace string
+
+	// CrossNamespaceRefCounting keeps secrets referenced by a cluster pool in any namespace. This lists every
+	// ClusterPool in the cluster on each delete, instead of only the pools in the deleted pool's namespace.
+	CrossNamespaceRefCounting bool
+
+	// Concurrency is the number of cluster pools reconciled in parallel, 1 when unset. Cleanup reference counts
+	// the secrets of the other pools in a namespace, so the cleanup of pools in the same namespace is serialized
+	// with a lock per namespace, see lockNamespace.
+	Concurrency int
+
+	// FinalizerName is the finalizer added to cluster pools, FINALIZER when unset. Give each controller instance
+	// running against the same cluster its own finalizer name.
+	FinalizerName string
+
+	// RequireManagedLabel only deletes referenced secrets carrying the namespace label key, with any value, or the
+	// MANAGED annotation, so secrets users created by hand are never deleted
+	RequireManagedLabel bool
+
+	// OwnerRefMode adds the cluster pool as an owner of the secrets it references, and leaves their removal to
+	// the garbage collector instead of deleting them. A shared secret has an owner reference per cluster pool.
+	OwnerRefMode bool
+
+	// ManagedSecretLabels selects auxiliary secrets, like proxy CAs or trust bundles, that are deleted with the
+	// namespace of the last cluster pool unless a cluster pool references them. None when empty.
+	ManagedSecretLabels map[string]string
+
+	// DisableFinalizer never adds the finalizer, cleanup then runs from the delete event with the last known state
+	// of the cluster pool. Nothing holds the pool then: a delete missed while the controller is down is never
+	// cleaned up, and a pool re-created right away races the cleanup. Pools that have the finalizer are still released.
+	DisableFinalizer bool
+
+	// DisableCleanup is the emergency kill-switch, populated from DISABLE_CLEANUP_ENV. Deleted cluster pools
+	// still have their finalizer removed, but none of their secrets or namespaces are deleted.
+	DisableCleanup bool
+
+	// BatchDelete removes the secrets carrying the managed-by label with one DeleteCollection when the last
+	// cluster pool of a namespace is deleted, right before the namespace once every check keeping the namespace
+	// has passed. Other deletions, kept namespaces, and all of them with CrossNamespaceRefCounting, delete secret by secret.
+	BatchDelete bool
+
+	// NamespaceDeletionGracePeriod delays deleting the namespace of the last cluster pool, which keeps its
+	// finalizer until the period has passed. The namespace is spared when a new cluster pool arrives meanwhile.
+	NamespaceDeletionGracePeriod time.Duration
+
+	// NamespaceDeletePropagation is the propagation policy of the namespace deletion, the API server default
+	// when empty. Foreground keeps the namespace until its objects are gone, Background returns right away.
+	NamespaceDeletePropagation metav1.DeletionPropagation
+
+	// CheckNamespaceEmptyBeforeDelete keeps the namespace of the last cluster pool while it still holds pods or
+	// persistent volume claims, for namespaces that also host workloads
+	CheckNamespaceEmptyBeforeDelete bool
+
+	// ExtraSecretRefPaths are dot separated field paths into the cluster pool, like
+	// "metadata.annotations.release-image-secret", naming more secrets of the pool namespace, such as image set or
+	// release image config secrets. They are cleaned up with the pool under the "extra" type, unless shared.
+	ExtraSecretRefPaths []string
+
+	// ProtectedNamespaces are glob patterns of namespaces that are never deleted, whatever their labels, on top
+	// of the built-in kube-* and openshift-* namespaces
+	ProtectedNamespaces []string
+
+	// AutoLabelNamespace stamps the managed-by label on the namespace of the first cluster pool created in it,
+	// when the namespace holds nothing else, so the namespace is deleted with its last cluster pool
+	AutoLabelNamespace bool
+
+	// WatchLabelSelector limits the reconciled cluster pools to those with matching labels, all pools when nil
+	WatchLabelSelector labels.Selector
+
+	// AuditConfigMap, when its name is set, records every resource deleted with a cluster pool
+	AuditConfigMap types.NamespacedName
+
+	// AnnotateLastCleanup records each cleanup that keeps the namespace, the cluster pool and the secrets it
+	// deleted, in the LAST_CLEANUP annotation of the namespace
+	AnnotateLastCleanup bool
+
+	// CleanupScope selects what is deleted with a cluster pool, CLEANUP_SCOPE_ALL when empty
+	CleanupScope CleanupScope
+
+	// ResyncInterval, when set, reconciles every watched cluster pool again at this interval, jittered, so missed
+	// or failed cleanups self-correct without a new event
+	ResyncInterval time.Duration
+
+	// EnableOrphanSweep periodically deletes the labeled secrets of labeled namespaces that no cluster pool
+	// references, which a missed cleanup left behind. OrphanSweepInterval is ORPHAN_SWEEP_INTERVAL when not set.
+	EnableOrphanSweep   bool
+	OrphanSweepInterval time.Duration
+
+	// ManageNamespaceFinalizer adds the finalizer to the labeled namespaces of cluster pools too, and cleans up
+	// the cluster pools of such a namespace when the namespace itself is deleted
+	ManageNamespaceFinalizer bool
+
+	// DeleteRetries retries each secret and namespace deletion this many times, with backoff, when it fails with
+	// a retryable error, before the cleanup fails. A failed deletion fails the cleanup right away when zero.
+	DeleteRetries int
+
+	// SecretNameResolver, when set, expands the secret refs of cluster pools into the names of the secrets
+	// they stand for, which are deleted and counted as referenced in their place
+	SecretNameResolver SecretNameResolver
+
+	// AfterSecretDelete and AfterNamespaceDelete, when set, are called after each secret and namespace the
+	// cleanup deletes, for tests to observe its steps. A secret outside the cluster pool namespace is named
+	// <namespace>/<name>.
+	AfterSecretDelete    func(name string)
+	AfterNamespaceDelete func(name string)
+
+	// MinPoolAgeForCleanup, when set, skips the cleanup of cluster pools deleted before they reached this age.
+	// Their finalizer is still removed.
+	MinPoolAgeForCleanup time.Duration
+
+	// OrphanMetricsInterval, when set, counts the orphaned secrets at this interval for the
+	// clusterpools_orphaned_secrets gauge, without deleting them
+	OrphanMetricsInterval time.Duration
+
+	// RefCacheTTL reuses the cluster pool list of a namespace for this long when deleting its pools, instead of
+	// listing the pools again for every deleted pool. Disabled when zero.
+	RefCacheTTL time.Duration
+
+	// WaitForClusterDeployments keeps the provider secret of a deleted cluster pool, and its namespace, while
+	// cluster deployments in the namespace still reference the secret, as their deprovision needs it. The other
+	// secrets are deleted right away.
+	WaitForClusterDeployments bool
+
+	// CleanupTimeBudget bounds the time of a cleanup pass. Once a secret step ends past the budget, the cleanup
+	// is requeued after CLEANUP_BUDGET_REQUEUE and continues with the next step. No limit when zero.
+	CleanupTimeBudget time.Duration
+
+	// ClientTimeout bounds the API calls of each cleanup and finalizer step, CLIENT_TIMEOUT when not set
+	ClientTimeout time.Duration
+
+	// Tracer, when set, traces every reconcile and cleanup as a span, no-op otherwise
+	Tracer trace.Tracer
+
+	// LeaderElection and LeaderElectionID are the manager's leader election settings, see ApplyLeaderElection.
+	// With LeaderElection set, the manager only starts the controller once this instance is the leader.
+	LeaderElection   bool
+	LeaderElectionID string
+
+	// tombstones holds the last state of cluster pools that were deleted before their cleanup ran
+	tombstones sync.Map
+	// cleanedUp holds the UIDs of deleted cluster pools whose cleanup already ran
+	cleanedUp sync.Map
+	// cleanupProgress holds the number of secret steps completed by the earlier passes of a cleanup, see
+	// CleanupTimeBudget
+	cleanupProgress sync.Map
+	// reconciledGenerations holds the last generation of each cluster pool whose reconcile found
+	// nothing left to do
+	reconciledGenerations sync.Map
+	// refCache holds the recent cluster pool lists, see RefCacheTTL
+	refCache refCache
+	// namespaceLocks holds a *sync.Mutex per namespace, serializing the cleanup of its cluster pools
+	namespaceLocks sync.Map
+
+	// backoff tracks retryable failures per request, see isRetryable
+	backoff     workqueue.TypedRateLimiter[ctrl.Request]
+	backoffOnce sync.Once
 }
 
-func (r *ClusterPoolsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ClusterPoolsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+
+	start := time.Now()
+	defer func() {
+		reconcileDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	ctx, span := getTracer(r).Start(ctx, "Reconcile", poolSpanAttributes(req.Namespace, req.Name))
+	defer func() {
+		endSpan(span, err)
+	}()
 
 	log := r.Log.WithValues("ClusterPoolsReconciler", req.NamespacedName)
 
+	r.backoffOnce.Do(func() {
+		r.backoff = workqueue.NewTypedItemExponentialFailureRateLimiter[ctrl.Request](BACKOFF_BASE_DELAY, BACKOFF_MAX_DELAY)
+	})
+
+	defer func() {
+		if err == nil {
+			r.backoff.Forget(req)
+			return
+		}
+
+		// The manager is shutting down, the cleanup stopped between steps and the finalizer stays for a retry
+		if ctx.Err() != nil {
+			result = ctrl.Result{Requeue: true}
+			log.V(INFO).Info("Reconcile interrupted by shutdown, requeueing", "error", err.Error())
+			err = nil
+			return
+		}
+
+		// Finalizer and status patches conflict while Hive updates the pool, requeue them with jittered backoff
+		// and without logging every conflict during rapid pool updates
+		if k8serrors.IsConflict(err) {
+			result = ctrl.Result{RequeueAfter: wait.Jitter(r.backoff.When(req), BACKOFF_JITTER_FACTOR)}
+			log.V(DEBUG).Info("Conflict, retrying with backoff", "requeueAfter", result.RequeueAfter.String(), "error", err.Error())
+			err = nil
+			return
+		}
+
+		reconcileErrorsTotal.Inc()
+
+		// Requeue transient API errors with backoff, instead of the immediate retry of a returned error
+		if isRetryable(err) {
+			result = ctrl.Result{RequeueAfter: r.backoff.When(req)}
+			log.V(WARN).Info("Retrying with backoff", "requeueAfter", result.RequeueAfter.String(), "error", err.Error())
+			err = nil
+		}
+	}()
+
 	var cp hivev1.ClusterPool
 	if err := r.Get(ctx, req.NamespacedName, &cp); err != nil {
+		if tombstone, found := r.tombstones.LoadAndDelete(req.NamespacedName); found {
+			log.V(INFO).Info("Resource deleted before cleanup, cleaning up from its last known state")
+			deleted, requeueAfter, err := deleteResources(ctx, r, tombstone.(*hivev1.ClusterPool))
+			logDeleted(log, deleted)
+			if err != nil || requeueAfter > 0 {
+				r.tombstones.Store(req.NamespacedName, tombstone)
+				return ctrl.Result{RequeueAfter: requeueAfter}, err
+			}
+			r.refCache.forget(tombstone.(*hivev1.ClusterPool))
+			return ctrl.Result{}, nil
+		}
+
 		log.V(INFO).Info("Resource deleted")
 
 		return ctrl.Result{}, nil
 	}
 
-	// Early exit
-	if cp.DeletionTimestamp == nil && controllerutil.ContainsFinalizer(&cp, FINALIZER) {
+	if annotationEnabled(cp.Annotations, PAUSED) {
+		log.V(INFO).Info("Reconcile paused", "name", cp.Name, "namespace", cp.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	if !watchesPool(r, &cp) {
+		log.V(DEBUG).Info("Cluster pool does not match the watch label selector", "name", cp.Name, "namespace", cp.Namespace)
 		return ctrl.Result{}, nil
 	}
 
-	target := cp.Name
-	log.V(INFO).Info("Reconcile cluster pool: " + target)
+	// Status updates do not change the generation, a pool already reconciled at its generation is left alone
+	if cp.DeletionTimestamp == nil && controllerutil.ContainsFinalizer(&cp, getFinalizerName(r)) {
+		if generation, found := r.reconciledGenerations.Load(req.NamespacedName); found && generation == cp.Generation {
+			log.V(DEBUG).Info("Cluster pool already reconciled at its generation", "generation", cp.Generation)
+			return ctrl.Result{}, nil
+		}
+	} else {
+		r.reconciledGenerations.Delete(req.NamespacedName)
+	}
+
+	if r.OwnerRefMode && cp.DeletionTimestamp == nil {
+		if err := setSecretOwnerReferences(ctx, r, &cp); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if r.ManageNamespaceFinalizer && cp.DeletionTimestamp == nil {
+		if err := setNamespaceFinalizer(ctx, r, &cp); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Early exit, only the secrets are checked again once the finalizer is there
+	if cp.DeletionTimestamp == nil && controllerutil.ContainsFinalizer(&cp, getFinalizerName(r)) {
+		result, err := checkSecrets(ctx, r, &cp)
+		if err == nil && result.IsZero() {
+			r.reconciledGenerations.Store(req.NamespacedName, cp.Generation)
+		}
+		return result, err
+	}
+
+	log.V(INFO).Info("Reconciling cluster pool", "name", cp.Name, "namespace", cp.Namespace)
 
 	if cp.DeletionTimestamp != nil {
-		if err := deleteResources(r, &cp); err != nil {
+		if err := setCleanupCondition(ctx, r, &cp, CONDITION_CLEANUP_COMPLETED, corev1.ConditionFalse, "InProgress",
+			"Cleaning up secrets and namespace"); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		deleted, requeueAfter, err := deleteResources(ctx, r, &cp)
+		logDeleted(log, deleted)
+		if auditErr := recordAudit(ctx, r, &cp, deleted); auditErr != nil {
+			log.V(WARN).Info("Failed to record the deleted resources in the audit config map", "configMap", r.AuditConfigMap.String(),
+				"resources", deleted, "error", auditErr.Error())
+		}
+		if annotateErr := annotateLastCleanup(ctx, r, &cp, deleted); annotateErr != nil {
+			log.V(WARN).Info("Failed to record the cleanup on the namespace", "annotation", LAST_CLEANUP, "error", annotateErr.Error())
+		}
+		if err != nil {
+			// An interrupted cleanup has not failed, it is retried once the controller is back
+			if ctx.Err() != nil {
+				return ctrl.Result{}, err
+			}
+			if statusErr := setCleanupCondition(ctx, r, &cp, CONDITION_CLEANUP_FAILED, corev1.ConditionTrue, "DeleteFailed",
+				err.Error()); statusErr != nil {
+				log.V(WARN).Info("Failed to set condition", "condition", string(CONDITION_CLEANUP_FAILED), "error", statusErr.Error())
+			}
+			return ctrl.Result{}, err
+		}
+		if requeueAfter > 0 {
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+		r.cleanedUp.Store(cp.UID, true)
+
+		if err := setCleanupCondition(ctx, r, &cp, CONDITION_CLEANUP_COMPLETED, corev1.ConditionTrue, "Completed",
+			"Cleaned up secrets and namespace"); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, removeFinalizer(ctx, r, &cp)
+	}
+
+	if r.AutoLabelNamespace {
+		if err := labelNamespace(ctx, r, &cp); err != nil {
 			return ctrl.Result{}, err
 		}
+	}
 
-		return ctrl.Result{}, removeFinalizer(r, &cp)
+	if r.DisableFinalizer {
+		log.V(DEBUG).Info("Finalizer management is disabled", "name", cp.Name, "namespace", cp.Namespace)
+	} else if err := setFinalizer(ctx, r, &cp); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, setFinalizer(r, &cp)
+	return checkSecrets(ctx, r, &cp)
 }
 
 func (r *ClusterPoolsReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&hivev1.ClusterPool{}).WithEventFilter(predicate.Funcs{
+	if r.Recorder == nil && mgr != nil {
+		r.Recorder = mgr.GetEventRecorderFor("clusterpools-controller")
+	}
+
+	if r.EnableOrphanSweep && mgr != nil {
+		if err := mgr.Add(&orphanSweeper{r: r}); err != nil {
+			return err
+		}
+	}
+
+	if r.OrphanMetricsInterval > 0 && mgr != nil {
+		if err := mgr.Add(&orphanCounter{r: r}); err != nil {
+			return err
+		}
+	}
+
+	if r.ManageNamespaceFinalizer && mgr != nil {
+		if err := ctrl.NewControllerManagedBy(mgr).Named("namespace").For(&corev1.Namespace{}).
+			WithEventFilter(namespaceFinalizerFilter(r)).Complete(&namespaceReconciler{r: r}); err != nil {
+			return err
+		}
+	}
+
+	// The pool filter is not an event filter, it would also see the secrets and namespaces
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&hivev1.ClusterPool{}, ctrlbuilder.WithPredicates(eventFilter(r))).WithOptions(controllerOptions(r)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+			return mapSecretToPools(ctx, r, obj)
+		}), ctrlbuilder.OnlyMetadata, ctrlbuilder.WithPredicates(secretDeleteFilter())).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+			return mapNamespaceToPools(ctx, r, obj)
+		}), ctrlbuilder.OnlyMetadata, ctrlbuilder.WithPredicates(namespaceCleanupFilter(r)))
+
+	if mgr != nil {
+		events := make(chan event.GenericEvent)
+		if err := mgr.Add(&poolResyncer{r: r, events: events}); err != nil {
+			return err
+		}
+		builder = builder.WatchesRawSource(source.Channel(events, &handler.EnqueueRequestForObject{}))
+	}
+
+	return builder.Complete(r)
+}
+
+func controllerOptions(r *ClusterPoolsReconciler) controller.Options {
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = 1 // This is the default
+	}
+
+	return controller.Options{
+		MaxConcurrentReconciles: concurrency,
+	}
+}
+
+// ApplyLeaderElection copies the reconciler's leader election settings onto the manager options, so only
+// the leader manager runs the controller. LeaderElectionID defaults to LEADER_ELECTION_ID.
+func (r *ClusterPoolsReconciler) ApplyLeaderElection(options *ctrl.Options) {
+	options.LeaderElection = r.LeaderElection
+	options.LeaderElectionID = r.LeaderElectionID
+	if options.LeaderElectionID == "" {
+		options.LeaderElectionID = LEADER_ELECTION_ID
+	}
+}
+
+// ApplyNamespace restricts the manager cache to the reconciler's Namespace, when it is set
+func (r *ClusterPoolsReconciler) ApplyNamespace(options *ctrl.Options) {
+	if r.Namespace != "" {
+		options.Cache.DefaultNamespaces = map[string]cache.Config{r.Namespace: {}}
+	}
+}
+
+func eventFilter(r *ClusterPoolsReconciler) predicate.Funcs {
+	return predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
-			return true
+			r.refCache.invalidate(e.Object.GetNamespace())
+			return watchesPool(r, e.Object)
 		},
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			return true
+			refs := refsChanged(r, e.ObjectOld, e.ObjectNew)
+			if refs {
+				r.refCache.invalidate(e.ObjectNew.GetNamespace())
+			}
+			if !watchesPool(r, e.ObjectNew) {
+				return false
+			}
+			return refs || lifecycleChanged(r, e.ObjectOld, e.ObjectNew)
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
-			return false
+			r.refCache.forget(e.Object)
+			r.cleanupProgress.Delete(client.ObjectKeyFromObject(e.Object))
+			r.reconciledGenerations.Delete(client.ObjectKeyFromObject(e.Object))
+			if !watchesPool(r, e.Object) {
+				return false
+			}
+			// Pools normally clean up while terminating, behind the finalizer. When that did not happen
+			// (the finalizer was stripped), keep the last known state so Reconcile can still clean up.
+			if cp, ok := e.Object.(*hivev1.ClusterPool); ok {
+				if _, cleaned := r.cleanedUp.LoadAndDelete(cp.UID); !cleaned {
+					r.tombstones.Store(client.ObjectKeyFromObject(cp), cp.DeepCopy())
+				}
+			}
+			return true
 		},
-	}).WithOptions(controller.Options{
-		MaxConcurrentReconciles: 1, // This is the default
-	}).Complete(r)
+	}
 }
 
-func setFinalizer(r *ClusterPoolsReconciler, cc *hivev1.ClusterPool) error {
-
-	patch := client.MergeFrom(cc.DeepCopy())
+// refsChanged reports whether an update changed the secrets a cluster pool references
+func refsChanged(r *ClusterPoolsReconciler, oldObj client.Object, newObj client.Object) bool {
+	oldCp, oldOk := oldObj.(*hivev1.ClusterPool)
+	newCp, newOk := newObj.(*hivev1.ClusterPool)
+	if !oldOk || !newOk {
+		return true
+	}
+	return !slices.Equal(getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, *oldCp),
+		getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, *newCp))
+}
 
-	controllerutil.AddFinalizer(cc, FINALIZER)
+// lifecycleChanged reports whether an update changed what Reconcile acts on: the deletion timestamp, the
+// finalizer, the paused and retain annotations, which decide whether the finalizer is needed, or whether the
+// pool is watched. Other label, annotation and status churn would only re-check the finalizer that is already there.
+func lifecycleChanged(r *ClusterPoolsReconciler, oldObj client.Object, newObj client.Object) bool {
+	finalizer := getFinalizerName(r)
+	return !oldObj.GetDeletionTimestamp().Equal(newObj.GetDeletionTimestamp()) ||
+		controllerutil.ContainsFinalizer(oldObj, finalizer) != controllerutil.ContainsFinalizer(newObj, finalizer) ||
+		oldObj.GetAnnotations()[PAUSED] != newObj.GetAnnotations()[PAUSED] ||
+		oldObj.GetAnnotations()[RETAIN_SECRETS] != newObj.GetAnnotations()[RETAIN_SECRETS] ||
+		oldObj.GetAnnotations()[RETAIN_NAMESPACE] != newObj.GetAnnotations()[RETAIN_NAMESPACE] ||
+		!watchesPool(r, oldObj)
+}
 
-	return r.Patch(context.Background(), cc, patch)
+// watchesPool reports whether the cluster pool is in the Namespace and matches the WatchLabelSelector. Pools
+// already carrying the finalizer are always watched, so their cleanup still completes after the label is removed.
+func watchesPool(r *ClusterPoolsReconciler, obj client.Object) bool {
+	if r.Namespace != "" && obj.GetNamespace() != r.Namespace {
+		return false
+	}
+	if r.WatchLabelSelector == nil || r.WatchLabelSelector.Matches(labels.Set(obj.GetLabels())) {
+		return true
+	}
+	return controllerutil.ContainsFinalizer(obj, getFinalizerName(r))
 }
 
-func removeFinalizer(r *ClusterPoolsReconciler, cc *hivev1.ClusterPool) error {
+// isRetryable reports whether an API error is transient and the request should be retried with backoff. An
+// internal server error is only retried when reading a referenced secret, where the apiserver briefly failing
+// would otherwise restart the cleanup with an error.
+func isRetryable(err error) bool {
+	return k8serrors.IsConflict(err) ||
+		k8serrors.IsServerTimeout(err) ||
+		k8serrors.IsTimeout(err) ||
+		k8serrors.IsTooManyRequests(err) ||
+		k8serrors.IsServiceUnavailable(err) ||
+		isSecretReadInternalError(err)
+}
 
-	if !controllerutil.ContainsFinalizer(cc, FINALIZER) {
-		return nil
+// deleteWithRetries calls del, and calls it again with backoff up to retries times while it fails with a
+// retryable error, so a single transient failure does not restart the whole cleanup
+func deleteWithRetries(retries int, del func() error) error {
+	if retries <= 0 {
+		return del()
 	}
+	backoff := retry.DefaultBackoff
+	backoff.Steps = retries + 1
+	return retry.OnError(backoff, isRetryable, del)
+}
 
-	controllerutil.RemoveFinalizer(cc, FINALIZER)
-
-	err := r.Update(context.Background(), cc)
-	if err == nil {
-		r.Log.V(INFO).Info("Removed finalizer on cluster pool: " + cc.Name)
+// withClientTimeout returns a context that expires after the reconciler's ClientTimeout
+func withClientTimeout(ctx context.Context, r *ClusterPoolsReconciler) (context.Context, context.CancelFunc) {
+	timeout := r.ClientTimeout
+	if timeout <= 0 {
+		timeout = CLIENT_TIMEOUT
 	}
-	return err
+	return context.WithTimeout(ctx, timeout)
+}
 
+func getCleanupScope(r *ClusterPoolsReconciler) CleanupScope {
+	if r.CleanupScope == "" {
+		return CLEANUP_SCOPE_ALL
+	}
+	return r.CleanupScope
 }
-func getCPDetails(cp hivev1.ClusterPool) (cpType string, providerSecretName string) {
-	if cp.Spec.Platform.AWS != nil {
-		return "aws", cp.Spec.Platform.AWS.CredentialsSecretRef.Name
-	} else if cp.Spec.Platform.GCP != nil {
-		return "gcp", cp.Spec.Platform.GCP.CredentialsSecretRef.Name
-	} else if cp.Spec.Platform.Azure != nil {
-		return "azure", cp.Spec.Platform.Azure.CredentialsSecretRef.Name
+
+func getFinalizerName(r *ClusterPoolsReconciler) string {
+	if r.FinalizerName == "" {
+		return FINALIZER
 	}
-	return "skip", ""
+	return r.FinalizerName
 }
-func deleteResources(r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) error {
-	ctx := context.Background()
-	log := r.Log
 
-	var cps hivev1.ClusterPoolList
-	if err := r.List(ctx, &cps, &client.ListOptions{Namespace: cp.Namespace}); err != nil {
+func setFinalizer(ctx context.Context, r *ClusterPoolsReconciler, cc *hivev1.ClusterPool) error {
 
-		if k8serrors.IsNotFound(err) {
-			log.V(INFO).Info("No Cluster Pools found")
-			return nil
-		} else {
-			return err
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
+
+	// The namespace deletion takes the pool and its secrets, a finalizer would only hold the namespace up
+	terminating, err := namespaceTerminating(ctx, r, cc)
+	if err != nil {
+		return err
+	}
+	if terminating {
+		r.Log.V(WARN).Info("Skipped adding finalizer, the namespace is terminating", "name", cc.Name, "namespace", cc.Namespace)
+		return nil
+	}
+
+	// A finalizer that guards no cleanup only slows down the deletion
+	cleanup, err := needsCleanup(ctx, r, cc)
+	if err != nil || !cleanup {
+		if err == nil {
+			r.Log.V(DEBUG).Info("Skipped adding finalizer, there is nothing to clean up", "name", cc.Name, "namespace", cc.Namespace)
 		}
+		return err
+	}
 
-	} else {
+	patch := client.MergeFrom(cc.DeepCopy())
 
-		// Remove secrets that are not used by any other cluster pool in the namespace
-		foundPullSecret := false
-		foundInstallConfigSecret := false
-		foundProviderSecret := false
+	controllerutil.AddFinalizer(cc, getFinalizerName(r))
 
-		cpType, providerSecretName := getCPDetails(*cp)
+	if err := r.Patch(ctx, cc, patch); err != nil {
+		return err
+	}
+	finalizersAddedTotal.Inc()
+	return nil
+}
 
-		for _, foundCp := range cps.Items {
+// checkSecrets sets the MissingSecret condition while secrets the cluster pool references are missing, and
+// requeues every MISSING_SECRET_REQUEUE until they exist, as secrets are not watched. The condition is cleared
+// once all secrets are found.
+func checkSecrets(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) (ctrl.Result, error) {
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
 
-			// Skip if the cluster pool being deleted is the element in the list
-			if cp.Name == foundCp.Name {
+	var missing []string
+	for _, name := range getSecretRefNames(*cp) {
+		if _, err := r.KubeClient.CoreV1().Secrets(cp.Namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+			if errors.IsNotFound(err) {
+				missing = append(missing, name)
 				continue
 			}
+			return ctrl.Result{}, err
+		}
+	}
 
-			if cp.Spec.PullSecretRef != nil && foundCp.Spec.PullSecretRef != nil && cp.Spec.PullSecretRef.Name == foundCp.Spec.PullSecretRef.Name {
-				foundPullSecret = true
-			}
-
-			if cp.Spec.InstallConfigSecretTemplateRef != nil && foundCp.Spec.InstallConfigSecretTemplateRef != nil && cp.Spec.InstallConfigSecretTemplateRef.Name == foundCp.Spec.InstallConfigSecretTemplateRef.Name {
-				foundInstallConfigSecret = true
-			}
+	if len(missing) > 0 {
+		r.Log.V(INFO).Info("Cluster pool references missing secrets", "name", cp.Name, "namespace", cp.Namespace, "missing", missing)
+		if err := setCleanupCondition(ctx, r, cp, CONDITION_MISSING_SECRET, corev1.ConditionTrue, "SecretsNotFound",
+			"Missing secrets: "+strings.Join(missing, ", ")); err != nil {
+			r.Log.V(WARN).Info("Failed to set condition", "condition", string(CONDITION_MISSING_SECRET), "error", err.Error())
+		}
+		return ctrl.Result{RequeueAfter: MISSING_SECRET_REQUEUE}, nil
+	}
 
-			// This needs to happen after the cp.Name == foundCp.Name check
+	if condition := getPoolCondition(cp, CONDITION_MISSING_SECRET); condition != nil && condition.Status == corev1.ConditionTrue {
+		return ctrl.Result{}, setCleanupCondition(ctx, r, cp, CONDITION_MISSING_SECRET, corev1.ConditionFalse, "AllSecretsFound",
+			"All referenced secrets exist")
+	}
+	return ctrl.Result{}, nil
+}
 
-			foundCpType, foundProviderSecretName := getCPDetails(foundCp)
+// getPoolCondition returns the cluster pool condition of the type, nil when it is not set
+func getPoolCondition(cp *hivev1.ClusterPool, conditionType hivev1.ClusterPoolConditionType) *hivev1.ClusterPoolCondition {
+	for i := range cp.Status.Conditions {
+		if cp.Status.Conditions[i].Type == conditionType {
+			return &cp.Status.Conditions[i]
+		}
+	}
+	return nil
+}
 
-			if cpType == foundCpType && providerSecretName == foundProviderSecretName {
-				foundProviderSecret = true
+// needsCleanup reports whether deleting the cluster pool cleans up anything: a secret it references and does
+// not retain, or its namespace when that carries the managed-by label
+func needsCleanup(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) (bool, error) {
+	if !r.OwnerRefMode {
+		for _, ref := range append(getCPSecretRefs(*cp), getExtraSecretRefs(r.ExtraSecretRefPaths, *cp)...) {
+			if !retainsSecret(cp, ref.secretType) {
+				return true, nil
 			}
 		}
+	}
+
+	if annotationEnabled(cp.Annotations, RETAIN_NAMESPACE) {
+		return false, nil
+	}
+
+	ns, err := r.KubeClient.CoreV1().Namespaces().Get(ctx, cp.Namespace, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	labelKey, labelValue := getNamespaceLabel(r)
+	return ns.Labels[labelKey] == labelValue && !annotationEnabled(ns.Annotations, RETAIN_NAMESPACE), nil
+}
 
-		log.V(INFO).Info(
-			fmt.Sprintf("Shared secrets found, install-config: %v, Pull secret: %v, Provider credential: %v",
-				foundInstallConfigSecret, foundPullSecret, foundProviderSecret))
+func removeFinalizer(ctx context.Context, r *ClusterPoolsReconciler, cc *hivev1.ClusterPool) error {
 
-		log.V(DEBUG).Info(fmt.Sprintf("providerSecretName: %v", providerSecretName))
+	if !controllerutil.ContainsFinalizer(cc, getFinalizerName(r)) {
+		return nil
+	}
 
-		if !foundInstallConfigSecret && cp.Spec.InstallConfigSecretTemplateRef != nil {
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
 
-			if err := deleteSecret(r, cp.Namespace, cp.Spec.InstallConfigSecretTemplateRef.Name); err != nil {
+	key := client.ObjectKeyFromObject(cc)
+	attempt := 0
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// After a conflict, or without the resource version the optimistic lock needs, the finalizer is removed
+		// from the latest state of the pool
+		if attempt++; attempt > 1 || cc.ResourceVersion == "" {
+			if err := r.Get(ctx, key, cc); err != nil {
+				if errors.IsNotFound(err) {
+					return nil
+				}
 				return err
 			}
-			log.V(INFO).Info("Deleted install-config secret: " + cp.Spec.InstallConfigSecretTemplateRef.Name)
+			if !controllerutil.ContainsFinalizer(cc, getFinalizerName(r)) {
+				return nil
+			}
 		}
 
-		if !foundPullSecret && cp.Spec.PullSecretRef != nil {
+		// The merge patch replaces the whole finalizer list, the optimistic lock makes it fail with a conflict
+		// rather than drop a finalizer added meanwhile
+		patch := client.MergeFromWithOptions(cc.DeepCopy(), client.MergeFromWithOptimisticLock{})
 
-			if err := deleteSecret(r, cp.Namespace, cp.Spec.PullSecretRef.Name); err != nil {
-				return err
+		controllerutil.RemoveFinalizer(cc, getFinalizerName(r))
+		metav1.SetMetaDataAnnotation(&cc.ObjectMeta, CLEANUP_COMPLETED_AT, time.Now().UTC().Format(time.RFC3339))
+
+		if err := r.Patch(ctx, cc, patch); err != nil {
+			return err
+		}
+		finalizersRemovedTotal.Inc()
+		return nil
+	})
+	if err != nil {
+		// The pool stays terminating until a later reconcile removes the finalizer, tell its owner why
+		recordWarning(r, cc, REASON_FINALIZER_REMOVAL_FAILED, "Failed to remove finalizer "+getFinalizerName(r)+" after "+
+			strconv.Itoa(attempt)+" attempts: "+err.Error())
+		return &ErrFinalizerUpdateFailed{ClusterPool: key, Err: err}
+	}
+	r.refCache.forget(cc)
+	r.Log.V(INFO).Info("Removed finalizer", "name", cc.Name, "namespace", cc.Namespace, "finalizer", getFinalizerName(r))
+	return nil
+
+}
+
+// setCleanupCondition sets a condition on the cluster pool status and patches it. A completed cleanup clears
+// any earlier CleanupFailed condition.
+func setCleanupCondition(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool, conditionType hivev1.ClusterPoolConditionType,
+	status corev1.ConditionStatus, reason string, message string) error {
+
+	patch := client.MergeFrom(cp.DeepCopy())
+
+	cp.Status.Conditions = updateCondition(cp.Status.Conditions, conditionType, status, reason, message)
+	if conditionType == CONDITION_CLEANUP_COMPLETED && status == corev1.ConditionTrue {
+		for _, condition := range cp.Status.Conditions {
+			if condition.Type == CONDITION_CLEANUP_FAILED {
+				cp.Status.Conditions = updateCondition(cp.Status.Conditions, CONDITION_CLEANUP_FAILED, corev1.ConditionFalse, reason, message)
+				break
 			}
-			log.V(INFO).Info("Deleted Pull-Secret secret: " + cp.Spec.PullSecretRef.Name)
 		}
+	}
 
-		if !foundProviderSecret && providerSecretName != "" {
+	return r.Status().Patch(ctx, cp, patch)
+}
 
-			if err := deleteSecret(r, cp.Namespace, providerSecretName); err != nil {
-				return err
+func updateCondition(conditions []hivev1.ClusterPoolCondition, conditionType hivev1.ClusterPoolConditionType,
+	status corev1.ConditionStatus, reason string, message string) []hivev1.ClusterPoolCondition {
+
+	now := metav1.Now()
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			if conditions[i].Status != status {
+				conditions[i].LastTransitionTime = now
 			}
-			log.V(INFO).Info("Deleted Provider-Credential secret: " + providerSecretName)
+			conditions[i].Status = status
+			conditions[i].Reason = reason
+			conditions[i].Message = message
+			conditions[i].LastProbeTime = now
+			return conditions
 		}
 	}
 
-	return nil
+	return append(conditions, hivev1.ClusterPoolCondition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
 }
 
-func deleteSecret(r *ClusterPoolsReconciler, namespace string, name string) error {
-	ctx := context.Background()
-	// Keep going if the secret is not found, but if found, remove it
-	_, err := r.KubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
-	if err != nil {
-		if errors.IsNotFound(err) {
-			r.Log.V(WARN).Info("Secret: " + name + " was not found")
-			return nil
+// secretRef is a secret referenced by a cluster pool, with the type it is referenced as. Hive only has local
+// object references on cluster pools, so the secret is always in the cluster pool namespace.
+type secretRef struct {
+	secretType string
+	name       string
+}
+
+// getCPSecretRefs returns the pull, install-config, provider and platform secrets of a cluster pool, skipping unset refs
+func getCPSecretRefs(cp hivev1.ClusterPool) []secretRef {
+	var refs []secretRef
+
+	if cp.Spec.PullSecretRef != nil && cp.Spec.PullSecretRef.Name != "" {
+		refs = append(refs, secretRef{SECRET_TYPE_PULL, cp.Spec.PullSecretRef.Name})
+	}
+	if cp.Spec.InstallConfigSecretTemplateRef != nil && cp.Spec.InstallConfigSecretTemplateRef.Name != "" {
+		refs = append(refs, secretRef{SECRET_TYPE_INSTALLCONFIG, cp.Spec.InstallConfigSecretTemplateRef.Name})
+	}
+	if extractor := getPlatformExtractor(&cp); extractor != nil {
+		for _, name := range extractor.ProviderSecretNames(&cp) {
+			if name != "" {
+				refs = append(refs, secretRef{SECRET_TYPE_PROVIDER, name})
+			}
 		}
-		return err
 	}
 
-	return r.KubeClient.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	return append(refs, getCPExtraSecrets(cp)...)
+}
+
+// getSecretRefNames returns the names of the secrets a cluster pool references, skipping unset refs
+func getSecretRefNames(cp hivev1.ClusterPool) []string {
+	var names []string
+	for _, ref := range getCPSecretRefs(cp) {
+		names = append(names, ref.name)
+	}
+	return names
+}
+
+// deleteResources removes the secrets, and with the last cluster pool the namespace, no other cluster pool uses.
+// It returns the deleted resources as "secret/<name>" and "namespace/<name>", also when it fails part way.
+// While the NamespaceDeletionGracePeriod runs, the namespace is kept and requeueAfter is the time left.
+// Nothing is deleted while cluster claims against the pool remain, requeueAfter is then CLAIMS_REQUEUE.
+// With WaitForClusterDeployments, requeueAfter is DEPROVISION_REQUEUE while the provider secret is kept.
+func deleteResources(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) (deleted []string, requeueAfter time.Duration, err error) {
+	ctx, span := getTracer(r).Start(ctx, "deleteResources", poolSpanAttributes(cp.Namespace, cp.Name))
+	defer func() {
+		span.SetAttributes(attribute.StringSlice("deleted", deleted))
+		endSpan(span, err)
+	}()
+
+	if r.DisableCleanup {
+		r.Log.V(WARN).Info("Cleanup is globally disabled, nothing is deleted", "clusterPool", cp.Name, "namespace", cp.Namespace, "env", DISABLE_CLEANUP_ENV)
+		return nil, 0, nil
+	}
+
+	scope := getCleanupScope(r)
+	if scope == CLEANUP_SCOPE_NONE {
+		r.Log.V(INFO).Info("Cleanup scope is None, nothing is deleted", "clusterPool", cp.Name, "namespace", cp.Namespace)
+		return nil, 0, nil
+	}
+
+	// Pools created and deleted right away, like in test loops, may hand their secrets to a pool created next
+	if age := time.Since(cp.CreationTimestamp.Time); r.MinPoolAgeForCleanup > 0 && age < r.MinPoolAgeForCleanup {
+		r.Log.V(INFO).Info("Skipped the cleanup of a cluster pool younger than the minimum age", "clusterPool", cp.Name, "namespace", cp.Namespace,
+			"age", age.Round(time.Second).String(), "minAge", r.MinPoolAgeForCleanup.String())
+		return nil, 0, nil
+	}
+
+	// A pool referencing no secrets, in a namespace that is not deleted with it, has nothing to clean up and
+	// skips the listing of pools and claims
+	if len(getCPSecretRefs(*cp)) == 0 && len(getExtraSecretRefs(r.ExtraSecretRefPaths, *cp)) == 0 {
+		ns, err := r.KubeClient.CoreV1().Namespaces().Get(ctx, cp.Namespace, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil, 0, nil
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if labelKey, labelValue := getNamespaceLabel(r); ns.Labels[labelKey] != labelValue {
+			retainUnlabeledNamespace(r, ns)
+			return nil, 0, nil
+		}
+	}
+
+	// The cleanup stays pending when it fails or is requeued, until a later attempt completes it
+	cleanupPending.WithLabelValues(cp.Namespace).Set(1)
+	defer func() {
+		if err == nil && requeueAfter == 0 {
+			cleanupPending.WithLabelValues(cp.Namespace).Set(0)
+			r.cleanupProgress.Delete(client.ObjectKeyFromObject(cp))
+		}
+	}()
+
+	unlock := lockNamespace(r, cp.Namespace)
+	defer unlock()
+
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
+	log := r.Log
+
+	// The namespace takes the secrets with it, unless it waits on this cleanup with ManageNamespaceFinalizer
+	terminating, err := namespaceTerminating(ctx, r, cp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if terminating {
+		log.V(INFO).Info("Namespace is terminating, leaving its secrets to the namespace deletion", "clusterPool", cp.Name, "namespace", cp.Namespace)
+		return nil, 0, nil
+	}
+
+	// Keep the secrets, and the finalizer, while users still hold clusters claimed from the pool
+	claims, err := getPoolClaims(ctx, r, cp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(claims) > 0 {
+		log.V(INFO).Info("Waiting for cluster claims to be released", "clusterPool", cp.Name, "namespace", cp.Namespace, "claims", claims)
+		return nil, CLAIMS_REQUEUE, nil
+	}
+
+	listOptions := &client.ListOptions{Namespace: cp.Namespace}
+	if r.CrossNamespaceRefCounting && r.Namespace == "" {
+		listOptions = &client.ListOptions{}
+	}
+
+	pools, err := listClusterPools(ctx, r, cp, listOptions)
+	if err != nil {
+
+		if k8serrors.IsNotFound(err) {
+			log.V(INFO).Info("No Cluster Pools found")
+			return nil, 0, nil
+		} else {
+			return nil, 0, err
+		}
+
+	} else {
+
+		// Trace the decision for every candidate secret, however the cleanup ends
+		defer func() {
+			log.V(DEBUG).Info("Secret cleanup decisions", "clusterPool", cp.Name, "namespace", cp.Namespace,
+				"secrets", getSecretDecisions(cp, pools, deleted))
+		}()
+
+		// Stop between steps on shutdown, rather than leaving the cleanup half done past the next step
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		otherPools := 0
+		for _, foundCp := range pools {
+			if cp.Namespace == foundCp.Namespace && cp.Name != foundCp.Name {
+				otherPools++
+			}
+		}
+
+		deprovisioning, err := getDeprovisioningClusterDeployments(ctx, r, cp)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Every secret step is attempted even when one fails, the namespace is only deleted when none failed
+		var secretErrs []error
+
+		// With a CleanupTimeBudget, the steps an earlier pass completed are skipped, and the pass stops once a
+		// step ends past the budget
+		key := client.ObjectKeyFromObject(cp)
+		completed := 0
+		if progress, found := r.cleanupProgress.Load(key); found && r.CleanupTimeBudget > 0 {
+			completed = progress.(int)
+		}
+		started := time.Now()
+		step := 0
+		runStep := func(run func() ([]string, error)) (overBudget bool) {
+			step++
+			if step <= completed {
+				return false
+			}
+			secrets, err := run()
+			for _, name := range secrets {
+				deleted = append(deleted, "secret/"+name)
+			}
+			if err != nil {
+				secretErrs = append(secretErrs, err)
+			} else if len(secretErrs) == 0 {
+				completed = step
+			}
+			return r.CleanupTimeBudget > 0 && time.Since(started) >= r.CleanupTimeBudget
+		}
+		continueLater := func() ([]string, time.Duration, error) {
+			if err := secretDeletionFailed(cp, secretErrs); err != nil {
+				return deleted, 0, err
+			}
+			// A pass keeping the provider secret for deprovisioning cluster deployments has not completed its steps
+			if len(deprovisioning) == 0 {
+				r.cleanupProgress.Store(key, completed)
+			}
+			log.V(INFO).Info("Cleanup time budget spent, continuing later", "clusterPool", cp.Name, "namespace", cp.Namespace,
+				"budget", r.CleanupTimeBudget.String(), "completedSteps", completed)
+			return deleted, CLEANUP_BUDGET_REQUEUE, nil
+		}
+
+		// With BatchDelete, the secrets of the last cluster pool are deleted with the namespace, see deleteNamespace
+		batch := r.BatchDelete && !r.OwnerRefMode && otherPools == 0 && !r.CrossNamespaceRefCounting &&
+			scope == CLEANUP_SCOPE_ALL && len(deprovisioning) == 0
+
+		// Remove secrets that are not used by any other cluster pool in the namespace (or cluster, with CrossNamespaceRefCounting).
+		// With OwnerRefMode, the garbage collector removes them once their last cluster pool is gone.
+		if r.OwnerRefMode {
+			log.V(DEBUG).Info("Leaving secrets to the garbage collector", "clusterPool", cp.Name)
+		} else if batch {
+			log.V(DEBUG).Info("Leaving secrets to the namespace batch delete", "clusterPool", cp.Name)
+		} else if runStep(func() ([]string, error) {
+			cleaner := newSecretCleaner(r)
+			cleaner.KeepProvider = len(deprovisioning) > 0
+			return cleaner.CleanupForPool(ctx, cp, pools)
+		}) && scope != CLEANUP_SCOPE_PROVIDER_ONLY {
+			return continueLater()
+		}
+
+		if err := ctx.Err(); err != nil {
+			return deleted, 0, err
+		}
+
+		// The namespace and the cluster deployment secrets hold secrets of every category
+		if scope == CLEANUP_SCOPE_PROVIDER_ONLY {
+			log.V(INFO).Info("Cleanup scope is ProviderOnly, keeping the cluster deployment secrets and the namespace",
+				"clusterPool", cp.Name, "namespace", cp.Namespace)
+			if otherPools == 0 {
+				recordNamespaceRetained(r, nil, cp.Namespace, "Kept namespace "+cp.Namespace+", the cleanup scope is "+string(scope))
+			}
+			if err := secretDeletionFailed(cp, secretErrs); err != nil || len(deprovisioning) == 0 {
+				return deleted, 0, err
+			}
+			return deleted, waitForDeprovision(r, cp, deprovisioning), nil
+		}
+
+		if !r.OwnerRefMode && runStep(func() ([]string, error) {
+			return deleteProvisionLeftovers(ctx, r, cp, pools)
+		}) {
+			return continueLater()
+		}
+
+		if runStep(func() ([]string, error) {
+			return deleteClusterDeploymentSecrets(ctx, r, cp)
+		}) {
+			return continueLater()
+		}
+
+		if err := secretDeletionFailed(cp, secretErrs); err != nil {
+			return deleted, 0, err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return deleted, 0, err
+		}
+
+		// The namespace would take the provider secret with it
+		if len(deprovisioning) > 0 {
+			return deleted, waitForDeprovision(r, cp, deprovisioning), nil
+		}
+
+		// The last cluster pool removes the namespace, when the namespace is managed by clusterpools
+		if otherPools == 0 {
+			// Give a replacement cluster pool the chance to claim the namespace, the finalizer holds the
+			// cluster pool until the grace period has passed and the pools are counted again
+			if remaining := namespaceGraceRemaining(r, cp); remaining > 0 {
+				log.V(INFO).Info("Waiting for the namespace deletion grace period", "namespace", cp.Namespace, "remaining", remaining.String())
+				return deleted, remaining, nil
+			}
+
+			var batchDelete func() ([]string, error)
+			if batch {
+				labelKey, labelValue := getNamespaceLabel(r)
+				batchDelete = func() ([]string, error) {
+					return newSecretCleaner(r).CleanupNamespace(ctx, cp, labelKey+"="+labelValue)
+				}
+			}
+
+			namespaceDeleted, err := deleteNamespace(ctx, r, cp, batchDelete)
+			deleted = append(deleted, namespaceDeleted...)
+			if err != nil {
+				return deleted, 0, &ErrNamespaceDeletionFailed{Namespace: cp.Namespace, Err: err}
+			}
+
+			// A kept namespace keeps its other labeled secrets, the secrets of the cluster pool go one by one
+			if batch && !slices.Contains(namespaceDeleted, "namespace/"+cp.Namespace) {
+				secrets, err := newSecretCleaner(r).CleanupForPool(ctx, cp, pools)
+				for _, name := range secrets {
+					deleted = append(deleted, "secret/"+name)
+				}
+				if err != nil {
+					return deleted, 0, secretDeletionFailed(cp, []error{err})
+				}
+			}
+		} else {
+			recordNamespaceRetained(r, nil, cp.Namespace, "Kept namespace "+cp.Namespace+", it is still used by "+strconv.Itoa(otherPools)+" other cluster pools")
+		}
+	}
+
+	return deleted, 0, nil
+}
+
+// secretDecision is the deleteResources decision trace of a candidate secret
+type secretDecision struct {
+	// Shared is set when another cluster pool references the secret
+	Shared  bool `json:"shared"`
+	Deleted bool `json:"deleted"`
+}
+
+// getSecretDecisions returns the decision per candidate secret, the secrets the cluster pool references and the
+// other secrets of its namespace that were deleted, given the listed pools and the deleted resources
+func getSecretDecisions(cp *hivev1.ClusterPool, pools []hivev1.ClusterPool, deleted []string) map[string]secretDecision {
+	decisions := map[string]secretDecision{}
+	for _, name := range getSecretRefNames(*cp) {
+		decisions[name] = secretDecision{}
+	}
+	for _, resource := range deleted {
+		if name, found := strings.CutPrefix(resource, "secret/"); found && !strings.Contains(name, "/") {
+			decisions[name] = secretDecision{Deleted: true}
+		}
+	}
+
+	for _, foundCp := range pools {
+		if cp.Name == foundCp.Name && cp.Namespace == foundCp.Namespace {
+			continue
+		}
+		for _, name := range getSecretRefNames(foundCp) {
+			if decision, found := decisions[name]; found {
+				decision.Shared = true
+				decisions[name] = decision
+			}
+		}
+	}
+	return decisions
+}
+
+// lockNamespace locks the namespace's cleanup against the other cluster pools of the namespace, so one does
+// not delete a secret while another is counting its references. It returns the unlock function.
+func lockNamespace(r *ClusterPoolsReconciler, namespace string) func() {
+	lock, _ := r.namespaceLocks.LoadOrStore(namespace, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// namespaceTerminating reports whether the namespace of the cluster pool is being deleted without waiting on
+// the namespace finalizer
+func namespaceTerminating(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) (bool, error) {
+	ns, err := r.KubeClient.CoreV1().Namespaces().Get(ctx, cp.Namespace, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if ns.DeletionTimestamp == nil && ns.Status.Phase != corev1.NamespaceTerminating {
+		return false, nil
+	}
+	return !controllerutil.ContainsFinalizer(ns, getFinalizerName(r)), nil
+}
+
+// getDeprovisioningClusterDeployments returns the names of the cluster deployments in the cluster pool namespace
+// that reference its provider secret, with WaitForClusterDeployments. None when the option is off.
+func getDeprovisioningClusterDeployments(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) ([]string, error) {
+	if !r.WaitForClusterDeployments || r.OwnerRefMode {
+		return nil, nil
+	}
+	_, providerSecretName := getCPDetails(*cp)
+	if providerSecretName == "" {
+		return nil, nil
+	}
+
+	var cds hivev1.ClusterDeploymentList
+	if err := r.List(ctx, &cds, client.InNamespace(cp.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, cd := range cds.Items {
+		// The cluster deployment platform is the cluster pool platform, down to the provider secret ref
+		if _, name := getCPDetails(hivev1.ClusterPool{Spec: hivev1.ClusterPoolSpec{Platform: cd.Spec.Platform}}); name == providerSecretName {
+			names = append(names, cd.Name)
+		}
+	}
+	return names, nil
+}
+
+// waitForDeprovision logs the cluster deployments the provider secret is kept for and returns DEPROVISION_REQUEUE
+func waitForDeprovision(r *ClusterPoolsReconciler, cp *hivev1.ClusterPool, clusterDeployments []string) time.Duration {
+	r.Log.V(INFO).Info("Waiting for cluster deployments to deprovision before deleting the provider secret", "clusterPool", cp.Name,
+		"namespace", cp.Namespace, "clusterDeployments", clusterDeployments)
+	return DEPROVISION_REQUEUE
+}
+
+// secretDeletionFailed returns the ErrSecretDeletionFailed joining the errors of the failed secret steps, nil when none failed
+func secretDeletionFailed(cp *hivev1.ClusterPool, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ErrSecretDeletionFailed{ClusterPool: client.ObjectKeyFromObject(cp), Err: stderrors.Join(errs...)}
+}
+
+// getPoolClaims returns the names of the cluster claims against the cluster pool
+func getPoolClaims(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) ([]string, error) {
+	var claims hivev1.ClusterClaimList
+	if err := r.List(ctx, &claims, &client.ListOptions{Namespace: cp.Namespace}); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, claim := range claims.Items {
+		if claim.Spec.ClusterPoolName == cp.Name {
+			names = append(names, claim.Name)
+		}
+	}
+	return names, nil
+}
+
+// namespaceGraceRemaining returns how much of the NamespaceDeletionGracePeriod is left since the cluster pool
+// was deleted
+func namespaceGraceRemaining(r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) time.Duration {
+	if r.NamespaceDeletionGracePeriod <= 0 || cp.DeletionTimestamp == nil {
+		return 0
+	}
+	return time.Until(cp.DeletionTimestamp.Add(r.NamespaceDeletionGracePeriod))
+}
+
+// logDeleted logs the resources removed by deleteResources
+func logDeleted(log logr.Logger, deleted []string) {
+	if len(deleted) > 0 {
+		log.V(INFO).Info("Deleted resources", "resources", deleted)
+	}
+}
+
+// deleteClusterDeploymentSecrets removes the kubeconfig and admin password secrets left by unclaimed
+// ClusterDeployments created from the cluster pool. Only secrets carrying the managed-by label are deleted.
+// It returns the deleted secrets as "<namespace>/<name>".
+func deleteClusterDeploymentSecrets(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) ([]string, error) {
+	var cds hivev1.ClusterDeploymentList
+	if err := r.List(ctx, &cds, client.InNamespace(r.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+
+	labelKey, labelValue := getNamespaceLabel(r)
+
+	for _, cd := range cds.Items {
+		poolRef := cd.Spec.ClusterPoolRef
+		if poolRef == nil || poolRef.Namespace != cp.Namespace || poolRef.PoolName != cp.Name || poolRef.ClaimName != "" {
+			continue
+		}
+		if cd.Spec.ClusterMetadata == nil {
+			continue
+		}
+
+		secretNames := []string{cd.Spec.ClusterMetadata.AdminKubeconfigSecretRef.Name}
+		if cd.Spec.ClusterMetadata.AdminPasswordSecretRef != nil {
+			secretNames = append(secretNames, cd.Spec.ClusterMetadata.AdminPasswordSecretRef.Name)
+		}
+
+		for _, name := range secretNames {
+			if name == "" {
+				continue
+			}
+
+			secret, err := r.KubeClient.CoreV1().Secrets(cd.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return deleted, err
+			}
+
+			if secret.Labels[labelKey] != labelValue {
+				r.Log.V(DEBUG).Info("Retaining unlabeled secret", "name", name, "namespace", cd.Namespace, "label", labelKey+"="+labelValue)
+				continue
+			}
+
+			if err := deleteWithRetries(r.DeleteRetries, func() error {
+				return r.KubeClient.CoreV1().Secrets(cd.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+			}); err != nil {
+				return deleted, err
+			}
+			deleted = append(deleted, cd.Namespace+"/"+name)
+			r.Log.V(INFO).Info("Deleted secret", "type", secretTypeDescriptions[SECRET_TYPE_CLUSTERDEPLOYMENT], "name", name, "namespace", cd.Namespace)
+			recordEvent(r, cp, REASON_SECRET_DELETED, "Deleted "+secretTypeDescriptions[SECRET_TYPE_CLUSTERDEPLOYMENT]+" secret: "+cd.Namespace+"/"+name)
+			secretsDeletedTotal.WithLabelValues(SECRET_TYPE_CLUSTERDEPLOYMENT).Inc()
+			if r.AfterSecretDelete != nil {
+				r.AfterSecretDelete(cd.Namespace + "/" + name)
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// getNamespaceLabel returns the label key and value that mark a namespace for deletion with its last cluster pool.
+// The same managed-by label marks the secrets this controller is allowed to delete beyond the pool's own refs.
+func getNamespaceLabel(r *ClusterPoolsReconciler) (string, string) {
+	labelKey := r.NamespaceLabel
+	if labelKey == "" {
+		labelKey = LABEL_NAMESPACE
+	}
+	labelValue := r.NamespaceLabelValue
+	if labelValue == "" {
+		labelValue = CLUSTERPOOLS
+	}
+	return labelKey, labelValue
+}
+
+// deleteNamespace removes the cluster pool namespace when it carries the managed-by label, after the secrets
+// matching ManagedSecretLabels and the secrets of batchDelete, when set. Every check that keeps the namespace runs before those secrets are deleted. It returns the deleted resources as "secret/<name>" and "namespace/<name>".
+func deleteNamespace(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool, batchDelete func() ([]string, error)) ([]string, error) {
+	namespace := cp.Namespace
+
+	if annotationEnabled(cp.Annotations, RETAIN_NAMESPACE) {
+		r.Log.V(INFO).Info("Skipped deleting namespace, the cluster pool has the retain annotation", "namespace", namespace, "clusterPool", cp.Name)
+		recordNamespaceRetained(r, nil, namespace, "Kept namespace "+namespace+", cluster pool "+cp.Name+" has the retain annotation")
+		return nil, nil
+	}
+
+	ns, err := r.KubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if labelKey, labelValue := getNamespaceLabel(r); ns.Labels[labelKey] != labelValue {
+		retainUnlabeledNamespace(r, ns)
+		return nil, nil
+	}
+
+	if annotationEnabled(ns.Annotations, RETAIN_NAMESPACE) {
+		r.Log.V(INFO).Info("Skipped deleting namespace, it has the retain annotation", "namespace", namespace)
+		recordNamespaceRetained(r, ns, namespace, "Kept namespace "+namespace+", it has the retain annotation")
+		return nil, nil
+	}
+
+	if isProtectedNamespace(r, namespace) {
+		r.Log.V(WARN).Info("Skipped deleting namespace, it is protected", "namespace", namespace, "clusterPool", cp.Name)
+		recordNamespaceRetained(r, ns, namespace, "Kept namespace "+namespace+", it is protected")
+		return nil, nil
+	}
+
+	// Checked before the managed secrets are deleted, so a namespace kept for its workloads keeps them too
+	if r.CheckNamespaceEmptyBeforeDelete {
+		workloads, err := getNamespaceWorkloads(ctx, r, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if len(workloads) > 0 {
+			r.Log.V(WARN).Info("Skipped deleting namespace, it still holds workloads", "namespace", namespace, "resources", workloads)
+			recordNamespaceRetained(r, ns, namespace, "Kept namespace "+namespace+", it still holds workloads: "+strings.Join(workloads, ", "))
+			return nil, nil
+		}
+	}
+
+	unexpected, err := getUnexpectedSecrets(ctx, r, cp)
+	if err != nil {
+		return nil, err
+	}
+	if len(unexpected) > 0 {
+		r.Log.V(WARN).Info("Skipped deleting namespace, it still holds managed secrets", "namespace", namespace, "secrets", unexpected)
+		recordNamespaceRetained(r, ns, namespace, "Kept namespace "+namespace+", it still holds managed secrets: "+strings.Join(unexpected, ", "))
+		return nil, nil
+	}
+
+	// The pools were counted at the start of the cleanup, count them again before anything is deleted with the
+	// namespace, so a cluster pool created meanwhile keeps the namespace and the managed secrets it may need
+	var cps hivev1.ClusterPoolList
+	if err := r.List(ctx, &cps, &client.ListOptions{Namespace: namespace}); err != nil {
+		return nil, err
+	}
+	for _, pool := range cps.Items {
+		if pool.Name != cp.Name {
+			r.Log.V(INFO).Info("Aborted deleting namespace, a cluster pool was created in it", "namespace", namespace, "clusterPool", pool.Name)
+			recordNamespaceRetained(r, ns, namespace, "Kept namespace "+namespace+", cluster pool "+pool.Name+" was created in it")
+			return nil, nil
+		}
+	}
+
+	var deleted []string
+	if batchDelete != nil {
+		secrets, err := batchDelete()
+		for _, name := range secrets {
+			deleted = append(deleted, "secret/"+name)
+		}
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	secrets, err := deleteManagedSecrets(ctx, r, cp)
+	for _, name := range secrets {
+		deleted = append(deleted, "secret/"+name)
+	}
+	if err != nil {
+		return deleted, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return deleted, err
+	}
+
+	options := metav1.DeleteOptions{}
+	if r.NamespaceDeletePropagation != "" {
+		options.PropagationPolicy = &r.NamespaceDeletePropagation
+	}
+	if err := deleteWithRetries(r.DeleteRetries, func() error {
+		return r.KubeClient.CoreV1().Namespaces().Delete(ctx, namespace, options)
+	}); err != nil {
+		return deleted, err
+	}
+	r.Log.V(INFO).Info("Deleted namespace", "namespace", namespace)
+	recordEvent(r, ns, REASON_NAMESPACE_DELETED, "Deleted namespace: "+namespace)
+	namespacesDeletedTotal.Inc()
+	if r.AfterNamespaceDelete != nil {
+		r.AfterNamespaceDelete(namespace)
+	}
+
+	return append(deleted, "namespace/"+namespace), nil
+}
+
+// retainUnlabeledNamespace logs and records that the namespace is kept, as it does not carry the namespace label
+func retainUnlabeledNamespace(r *ClusterPoolsReconciler, ns *corev1.Namespace) {
+	labelKey, labelValue := getNamespaceLabel(r)
+	r.Log.V(DEBUG).Info("Retaining unlabeled namespace", "namespace", ns.Name, "label", labelKey+"="+labelValue)
+	recordNamespaceRetained(r, ns, ns.Name, "Kept namespace "+ns.Name+", it does not have the "+labelKey+"="+labelValue+" label")
+}
+
+// deleteManagedSecrets removes the secrets in the cluster pool namespace that match the ManagedSecretLabels
+// and that the cluster pool does not reference, and returns their names
+func deleteManagedSecrets(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) ([]string, error) {
+	if len(r.ManagedSecretLabels) == 0 {
+		return nil, nil
+	}
+
+	secrets, err := r.KubeClient.CoreV1().Secrets(cp.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(r.ManagedSecretLabels).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refNames := getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, *cp)
+
+	var deleted []string
+	for _, secret := range secrets.Items {
+		if slices.Contains(refNames, secret.Name) {
+			continue
+		}
+		if err := deleteWithRetries(r.DeleteRetries, func() error {
+			return r.KubeClient.CoreV1().Secrets(cp.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		}); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		r.Log.V(INFO).Info("Deleted secret", "type", secretTypeDescriptions[SECRET_TYPE_LABELED], "name", secret.Name, "namespace", cp.Namespace)
+		newSecretCleaner(r).OnDelete(cp, SECRET_TYPE_LABELED, secret.Name)
+		deleted = append(deleted, secret.Name)
+	}
+	return deleted, nil
+}
+
+// deleteProvisionLeftovers removes the secrets a failed provisioning left in the cluster pool namespace: named
+// "<cluster pool>-..." and carrying the namespace label key, but referenced by none of the pools. Secrets that
+// also carry the name prefix of another pool in the namespace are kept. It returns the deleted names.
+func deleteProvisionLeftovers(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool, pools []hivev1.ClusterPool) ([]string, error) {
+	labelKey, _ := getNamespaceLabel(r)
+
+	secrets, err := r.KubeClient.CoreV1().Secrets(cp.Namespace).List(ctx, metav1.ListOptions{LabelSelector: labelKey})
+	if err != nil {
+		return nil, err
+	}
+
+	refNames := getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, *cp)
+	for _, foundCp := range pools {
+		refNames = append(refNames, getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, foundCp)...)
+	}
+	isLeftover := func(name string) bool {
+		if !strings.HasPrefix(name, cp.Name+"-") || slices.Contains(refNames, name) {
+			return false
+		}
+		return !slices.ContainsFunc(pools, func(foundCp hivev1.ClusterPool) bool {
+			return foundCp.Namespace == cp.Namespace && foundCp.Name != cp.Name && strings.HasPrefix(name, foundCp.Name+"-")
+		})
+	}
+
+	var deleted []string
+	for _, secret := range secrets.Items {
+		if !isLeftover(secret.Name) {
+			continue
+		}
+		if err := deleteWithRetries(r.DeleteRetries, func() error {
+			return r.KubeClient.CoreV1().Secrets(cp.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		}); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		r.Log.V(INFO).Info("Deleted secret left by a failed provisioning", "name", secret.Name, "namespace", cp.Namespace, "clusterPool", cp.Name)
+		newSecretCleaner(r).OnDelete(cp, SECRET_TYPE_LABELED, secret.Name)
+		deleted = append(deleted, secret.Name)
+	}
+	return deleted, nil
+}
+
+// labelNamespace adds the managed-by label to the namespace of the first cluster pool created in it. System
+// namespaces, namespaces with other cluster pools and namespaces holding other workloads are left alone.
+func labelNamespace(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) error {
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
+	namespace := cp.Namespace
+
+	if isSystemNamespace(namespace) {
+		return nil
+	}
+
+	ns, err := r.KubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	labelKey, labelValue := getNamespaceLabel(r)
+	if _, found := ns.Labels[labelKey]; found {
+		return nil
+	}
+
+	var cps hivev1.ClusterPoolList
+	if err := r.List(ctx, &cps, &client.ListOptions{Namespace: namespace}); err != nil {
+		return err
+	}
+	for _, foundCp := range cps.Items {
+		if foundCp.Name != cp.Name {
+			r.Log.V(DEBUG).Info("Not labeling namespace, it has other cluster pools", "namespace", namespace)
+			return nil
+		}
+	}
+
+	resources, err := getNamespaceResources(ctx, r, cp)
+	if err != nil {
+		return err
+	}
+	if len(resources) > 0 {
+		r.Log.V(INFO).Info("Not labeling namespace, it holds other resources", "namespace", namespace, "resources", resources)
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{labelKey: labelValue},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.KubeClient.CoreV1().Namespaces().Patch(ctx, namespace, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	r.Log.V(INFO).Info("Labeled namespace", "namespace", namespace, "label", labelKey+"="+labelValue)
+
+	return nil
+}
+
+// isSystemNamespace reports whether the namespace belongs to the platform, and is never labeled for deletion
+func isSystemNamespace(namespace string) bool {
+	return namespace == "default" ||
+		strings.HasPrefix(namespace, "kube-") ||
+		strings.HasPrefix(namespace, "openshift") ||
+		strings.HasPrefix(namespace, "open-cluster-management")
+}
+
+// builtinProtectedNamespaces are never deleted, even when labeled
+var builtinProtectedNamespaces = []string{"kube-*", "openshift-*"}
+
+// isProtectedNamespace reports whether the namespace matches a built-in or a configured protected pattern
+func isProtectedNamespace(r *ClusterPoolsReconciler, namespace string) bool {
+	for _, pattern := range slices.Concat(builtinProtectedNamespaces, r.ProtectedNamespaces) {
+		if matched, _ := path.Match(pattern, namespace); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// annotationEnabled reports whether the boolean annotation is set to true, parsed like DISABLE_CLEANUP_ENV
+func annotationEnabled(annotations map[string]string, key string) bool {
+	enabled, _ := strconv.ParseBool(annotations[key])
+	return enabled
+}
+
+// namespaceDefaultConfigMaps are created by the platform in every namespace
+var namespaceDefaultConfigMaps = []string{"kube-root-ca.crt", "openshift-service-ca.crt"}
+
+// getNamespaceWorkloads returns the pods and persistent volume claims in the namespace
+func getNamespaceWorkloads(ctx context.Context, r *ClusterPoolsReconciler, namespace string) ([]string, error) {
+	var workloads []string
+
+	pods, err := r.KubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		workloads = append(workloads, "pod/"+pod.Name)
+	}
+
+	claims, err := r.KubeClient.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, claim := range claims.Items {
+		workloads = append(workloads, "persistentvolumeclaim/"+claim.Name)
+	}
+	return workloads, nil
+}
+
+// getNamespaceResources returns the pods, config maps and secrets in the cluster pool namespace, other than
+// the cluster pool's own secrets and what the platform creates in every namespace
+func getNamespaceResources(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) ([]string, error) {
+	var resources []string
+
+	pods, err := r.KubeClient.CoreV1().Pods(cp.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		resources = append(resources, "pod/"+pod.Name)
+	}
+
+	configMaps, err := r.KubeClient.CoreV1().ConfigMaps(cp.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, configMap := range configMaps.Items {
+		if !slices.Contains(namespaceDefaultConfigMaps, configMap.Name) {
+			resources = append(resources, "configmap/"+configMap.Name)
+		}
+	}
+
+	secrets, err := r.KubeClient.CoreV1().Secrets(cp.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	refNames := getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, *cp)
+	for _, secret := range secrets.Items {
+		// Service account tokens and pull secrets are generated for the namespace's default service accounts
+		if secret.Type == corev1.SecretTypeServiceAccountToken || secret.Type == corev1.SecretTypeDockercfg {
+			continue
+		}
+		if !slices.Contains(refNames, secret.Name) {
+			resources = append(resources, "secret/"+secret.Name)
+		}
+	}
+
+	return resources, nil
+}
+
+// setSecretOwnerReferences adds the cluster pool as an owner of the secrets it references, so the garbage
+// collector deletes a secret once no cluster pool owns it
+func setSecretOwnerReferences(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) error {
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
+
+	for _, name := range getSecretRefNames(*cp) {
+		secret, err := r.KubeClient.CoreV1().Secrets(cp.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		ownerReferences := slices.Clone(secret.OwnerReferences)
+		if err := controllerutil.SetOwnerReference(cp, secret, r.Scheme); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(ownerReferences, secret.OwnerReferences) {
+			continue
+		}
+
+		if _, err := r.KubeClient.CoreV1().Secrets(cp.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		r.Log.V(INFO).Info("Set owner reference", "secret", name, "namespace", cp.Namespace, "clusterPool", cp.Name)
+	}
+
+	return nil
+}
+
+// getUnexpectedSecrets returns the secrets left in the cluster pool namespace that carry the managed-by label key,
+// with any value, and are not referenced by the cluster pool. The pool cleanup has removed its own secrets by now,
+// and the secrets matching ManagedSecretLabels are deleted with the namespace, so these belong to something else
+// that deleting the namespace would take with it.
+func getUnexpectedSecrets(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) ([]string, error) {
+	labelKey, _ := getNamespaceLabel(r)
+
+	secrets, err := r.KubeClient.CoreV1().Secrets(cp.Namespace).List(ctx, metav1.ListOptions{LabelSelector: labelKey})
+	if err != nil {
+		return nil, err
+	}
+
+	refNames := getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, *cp)
+	isManaged := func(secret corev1.Secret) bool {
+		return len(r.ManagedSecretLabels) > 0 && labels.SelectorFromSet(r.ManagedSecretLabels).Matches(labels.Set(secret.Labels))
+	}
+
+	var unexpected []string
+	for _, secret := range secrets.Items {
+		if !slices.Contains(refNames, secret.Name) && !isDerivedInstallConfig(*cp, secret.Name) && !isManaged(secret) {
+			unexpected = append(unexpected, secret.Name)
+		}
+	}
+	return unexpected, nil
+}
+
+// newSecretCleaner returns a SecretCleaner that records an event and a metric for each deleted secret
+func newSecretCleaner(r *ClusterPoolsReconciler) *SecretCleaner {
+	return &SecretCleaner{
+		KubeClient:    r.KubeClient,
+		Log:           r.Log,
+		ProviderOnly:  getCleanupScope(r) == CLEANUP_SCOPE_PROVIDER_ONLY,
+		ManagedLabel:  getManagedLabel(r),
+		Retries:       r.DeleteRetries,
+		ResolveName:   r.SecretNameResolver,
+		ExtraRefPaths: r.ExtraSecretRefPaths,
+		OnDelete: func(cp *hivev1.ClusterPool, secretType string, name string) {
+			recordEvent(r, cp, REASON_SECRET_DELETED, "Deleted "+secretTypeDescriptions[secretType]+" secret: "+name)
+			secretsDeletedTotal.WithLabelValues(secretType).Inc()
+			if r.AfterSecretDelete != nil {
+				r.AfterSecretDelete(name)
+			}
+		},
+	}
+}
+
+// getManagedLabel returns the label key referenced secrets must carry, none unless RequireManagedLabel is set
+func getManagedLabel(r *ClusterPoolsReconciler) string {
+	if !r.RequireManagedLabel {
+		return ""
+	}
+	labelKey, _ := getNamespaceLabel(r)
+	return labelKey
+}
+
+// recordNamespaceRetained emits a NamespaceRetained event on the namespace, ns when it was read already
+func recordNamespaceRetained(r *ClusterPoolsReconciler, ns *corev1.Namespace, namespace string, message string) {
+	if ns == nil {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	}
+	recordEvent(r, ns, REASON_NAMESPACE_RETAINED, message)
+}
+
+// recordEvent emits a Normal event on the object when an event recorder is configured
+func recordEvent(r *ClusterPoolsReconciler, obj runtime.Object, reason string, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(obj, corev1.EventTypeNormal, reason, message)
+	}
+}
+
+// recordWarning emits a Warning event on the object when an event recorder is configured
+func recordWarning(r *ClusterPoolsReconciler, obj runtime.Object, reason string, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(obj, corev1.EventTypeWarning, reason, message)
+	}
 }
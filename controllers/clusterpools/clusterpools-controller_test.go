@@ -3,24 +3,45 @@ package clusterpools
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
 
+	"github.com/go-logr/logr/funcr"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/openshift/hive/apis/hive/v1/aws"
 	"github.com/openshift/hive/apis/hive/v1/azure"
+	"github.com/openshift/hive/apis/hive/v1/baremetal"
 	"github.com/openshift/hive/apis/hive/v1/gcp"
+	"github.com/openshift/hive/apis/hive/v1/ibmcloud"
+	"github.com/openshift/hive/apis/hive/v1/none"
+	"github.com/openshift/hive/apis/hive/v1/nutanix"
+	"github.com/openshift/hive/apis/hive/v1/openstack"
+	"github.com/openshift/hive/apis/hive/v1/ovirt"
+	"github.com/openshift/hive/apis/hive/v1/vsphere"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zapcore"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
@@ -63,6 +84,51 @@ func getSecret(namespace string, name string) *corev1.Secret {
 	}
 }
 
+func getNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
+func getClusterDeployment(namespace string, poolName string, claimName string) *hivev1.ClusterDeployment {
+	return &hivev1.ClusterDeployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      namespace,
+			Namespace: namespace,
+		},
+		Spec: hivev1.ClusterDeploymentSpec{
+			ClusterPoolRef: &hivev1.ClusterPoolReference{
+				Namespace: CP_NAMESPACE,
+				PoolName:  poolName,
+				ClaimName: claimName,
+			},
+			ClusterMetadata: &hivev1.ClusterMetadata{
+				AdminKubeconfigSecretRef: corev1.LocalObjectReference{Name: namespace + "-admin-kubeconfig"},
+				AdminPasswordSecretRef:   &corev1.LocalObjectReference{Name: namespace + "-admin-password"},
+			},
+		},
+	}
+}
+
+func seedSecrets(ctx context.Context, cpr *ClusterPoolsReconciler, namespace string, names ...string) {
+	for _, name := range names {
+		cpr.KubeClient.CoreV1().Secrets(namespace).Create(ctx, getSecret(namespace, name), v1.CreateOptions{})
+	}
+}
+
+func secretExists(ctx context.Context, cpr *ClusterPoolsReconciler, namespace string, name string) bool {
+	_, err := cpr.KubeClient.CoreV1().Secrets(namespace).Get(ctx, name, v1.GetOptions{})
+	return err == nil
+}
+
+func namespaceExists(ctx context.Context, cpr *ClusterPoolsReconciler, name string) bool {
+	_, err := cpr.KubeClient.CoreV1().Namespaces().Get(ctx, name, v1.GetOptions{})
+	return err == nil
+}
+
 func GetClusterPoolsReconciler() *ClusterPoolsReconciler {
 
 	// Log levels: DebugLevel  DebugLevel
@@ -70,7 +136,7 @@ func GetClusterPoolsReconciler() *ClusterPoolsReconciler {
 
 	return &ClusterPoolsReconciler{
 		KubeClient: kubefake.NewSimpleClientset(),
-		Client:     clientfake.NewClientBuilder().WithScheme(s).Build(),
+		Client:     clientfake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&hivev1.ClusterPool{}).Build(),
 		Log:        ctrl.Log.WithName("controllers").WithName("ClusterPoolsReconciler"),
 		Scheme:     s,
 	}
@@ -100,6 +166,31 @@ func GetClusterPool(namespace string, name string, poolType string) *hivev1.Clus
 		cp.Spec.Platform.GCP = &gcp.Platform{CredentialsSecretRef: corev1.LocalObjectReference{Name: "secret03"}}
 	case "azure":
 		cp.Spec.Platform.Azure = &azure.Platform{CredentialsSecretRef: corev1.LocalObjectReference{Name: "secret03"}}
+	case "openstack":
+		cp.Spec.Platform.OpenStack = &openstack.Platform{CredentialsSecretRef: corev1.LocalObjectReference{Name: "secret03"}}
+	case "none":
+		cp.Spec.Platform.None = &none.Platform{}
+	case "ibmcloud":
+		cp.Spec.Platform.IBMCloud = &ibmcloud.Platform{CredentialsSecretRef: corev1.LocalObjectReference{Name: "secret03"}}
+	case "vsphere":
+		cp.Spec.Platform.VSphere = &vsphere.Platform{
+			CredentialsSecretRef:  corev1.LocalObjectReference{Name: "secret03"},
+			CertificatesSecretRef: corev1.LocalObjectReference{Name: "secret04"},
+		}
+	case "nutanix":
+		cp.Spec.Platform.Nutanix = &nutanix.Platform{
+			CredentialsSecretRef:  corev1.LocalObjectReference{Name: "secret03"},
+			CertificatesSecretRef: corev1.LocalObjectReference{Name: "secret04"},
+		}
+	case "ovirt":
+		cp.Spec.Platform.Ovirt = &ovirt.Platform{
+			CredentialsSecretRef:  corev1.LocalObjectReference{Name: "secret03"},
+			CertificatesSecretRef: corev1.LocalObjectReference{Name: "secret04"},
+		}
+	case "baremetal":
+		cp.Spec.Platform.BareMetal = &baremetal.Platform{
+			LibvirtSSHPrivateKeySecretRef: corev1.LocalObjectReference{Name: "secret05"},
+		}
 	default:
 		panic(errors.New("GetClusterPool: Invalid poolType: " + poolType))
 	}
@@ -107,6 +198,13 @@ func GetClusterPool(namespace string, name string, poolType string) *hivev1.Clus
 	return cp
 }
 
+// createDeletingClusterPool stores the cluster pool with the finalizer and deletes it, leaving it terminating
+func createDeletingClusterPool(ctx context.Context, cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool) {
+	cp.Finalizers = append(cp.Finalizers, FINALIZER)
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	cpr.Client.Delete(ctx, cp)
+}
+
 func GetClusterPoolNoRefs(namespace string, name string, poolType string) *hivev1.ClusterPool {
 	cp := &hivev1.ClusterPool{
 		ObjectMeta: v1.ObjectMeta{
@@ -171,7 +269,7 @@ func TestReconcileClusterPoolDeleteAws(t *testing.T) {
 	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret02"), v1.CreateOptions{})
 	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
 
-	err := deleteResources(cpr, cp)
+	_, _, err := deleteResources(ctx, cpr, cp)
 
 	assert.Nil(t, err, "nil, when clusterClaim is found reconcile was successful")
 
@@ -188,6 +286,29 @@ func TestReconcileClusterPoolDeleteAws(t *testing.T) {
 	assert.Contains(t, err.Error(), " not found", "secret should not be found")
 }
 
+func TestReconcileClusterPoolDeleteSecretInOtherNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client.Create(ctx, GetClusterPool("other-namespace", CP_NAME, "aws"), &client.CreateOptions{})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets("other-namespace").Create(ctx, getSecret("other-namespace", "secret03"), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err), "the secret in the pool namespace is deleted, a pool in another namespace does not reference it")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets("other-namespace").Get(ctx, "secret03", v1.GetOptions{})
+	assert.Nil(t, err, "the secret with the same name in another namespace is kept")
+}
+
 func TestReconcileClusterPoolDeleteGcp(t *testing.T) {
 
 	ctx := context.Background()
@@ -201,7 +322,7 @@ func TestReconcileClusterPoolDeleteGcp(t *testing.T) {
 	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret02"), v1.CreateOptions{})
 	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
 
-	err := deleteResources(cpr, cp)
+	_, _, err := deleteResources(ctx, cpr, cp)
 	assert.Nil(t, err, "nil, when clusterClaim is found reconcile was successful")
 
 	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret01", v1.GetOptions{})
@@ -230,7 +351,7 @@ func TestReconcileClusterPoolDeleteAzure(t *testing.T) {
 	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret02"), v1.CreateOptions{})
 	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
 
-	err := deleteResources(cpr, cp)
+	_, _, err := deleteResources(ctx, cpr, cp)
 
 	assert.Nil(t, err, "nil, when clusterClaim is found reconcile was successful")
 
@@ -459,3 +580,2811 @@ func TestReconcileClusterPoolDeleteWithFinalizer(t *testing.T) {
 
 	assert.Nil(t, err, "nil, when clusterPool delete reconcile successful")
 }
+
+func TestReconcileClusterPoolDeleteOpenStack(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "openstack")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when secret was successfully deleted")
+	assert.Contains(t, err.Error(), " not found", "secret should not be found")
+}
+
+func TestReconcileClusterPoolDeleteSharedSecretsOpenStack(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "openstack")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "openstack"), &client.CreateOptions{})
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.Nil(t, err, "nil, when shared provider secret was not deleted")
+}
+
+func TestReconcileClusterPoolDeleteVSphere(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret04"), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when secret was successfully deleted")
+	assert.Contains(t, err.Error(), " not found", "secret should not be found")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret04", v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when certificates secret was successfully deleted")
+	assert.Contains(t, err.Error(), " not found", "secret should not be found")
+}
+
+func TestReconcileClusterPoolDeleteVSphereEmptyRefs(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Spec.Platform.VSphere = &vsphere.Platform{}
+
+	_, _, err := deleteResources(context.Background(), cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete with empty vSphere refs was successful")
+
+	for _, action := range cpr.KubeClient.(*kubefake.Clientset).Actions() {
+		if action.GetResource().Resource == "secrets" {
+			assert.NotEqual(t, "delete", action.GetVerb(), "no provider secret delete should be attempted")
+		}
+	}
+}
+
+func TestReconcileClusterPoolDeleteOpenStackCertificates(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "openstack")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Spec.Platform.OpenStack.CertificatesSecretRef = &corev1.LocalObjectReference{Name: "secret04"}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03", "secret04")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "provider secret should be deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "CA certificates secret should be deleted")
+}
+
+func TestReconcileClusterPoolDeleteSharedCertificatesOpenStack(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "openstack")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Spec.Platform.OpenStack.CertificatesSecretRef = &corev1.LocalObjectReference{Name: "secret04"}
+
+	cp2 := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "openstack")
+	cp2.Spec.Platform.OpenStack.CredentialsSecretRef.Name = "secret13"
+	cp2.Spec.Platform.OpenStack.CertificatesSecretRef = &corev1.LocalObjectReference{Name: "secret04"}
+	cpr.Client.Create(ctx, cp2, &client.CreateOptions{})
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03", "secret04")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "unshared provider secret should be deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "shared CA certificates secret should be kept")
+}
+
+func TestReconcileClusterPoolDeleteSharedCertificatesVSphere(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cp2 := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "vsphere")
+	cp2.Spec.Platform.VSphere.CredentialsSecretRef.Name = "secret13"
+	cpr.Client.Create(ctx, cp2, &client.CreateOptions{})
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03", "secret04")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "unshared provider secret should be deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "shared certificates secret should be kept")
+}
+
+func TestReconcileClusterPoolDeleteBareMetal(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "baremetal")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret05")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret05"), "SSH private key secret should be deleted")
+}
+
+func TestReconcileClusterPoolDeleteSharedSecretsBareMetal(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "baremetal")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "baremetal"), &client.CreateOptions{})
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret05")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret05"), "shared SSH private key secret should be kept")
+}
+
+func TestGetCPExtraSecrets(t *testing.T) {
+
+	assert.Empty(t, getCPExtraSecrets(*GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")), "aws has no platform secrets")
+	assert.Empty(t, getCPExtraSecrets(*GetClusterPool(CP_NAMESPACE, CP_NAME, "openstack")), "openstack without a CA certificates secret")
+	assert.Equal(t, []secretRef{{SECRET_TYPE_CERTIFICATES, "secret04"}},
+		getCPExtraSecrets(*GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")))
+	assert.Equal(t, []secretRef{{SECRET_TYPE_SSH, "secret05"}},
+		getCPExtraSecrets(*GetClusterPool(CP_NAMESPACE, CP_NAME, "baremetal")))
+}
+
+func TestReconcileClusterPoolDeleteIBMCloud(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "ibmcloud")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when secret was successfully deleted")
+	assert.Contains(t, err.Error(), " not found", "secret should not be found")
+}
+
+func TestReconcileClusterPoolDeleteSharedSecretsIBMCloud(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "ibmcloud")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "ibmcloud"), &client.CreateOptions{})
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.Nil(t, err, "nil, when shared provider secret was not deleted")
+}
+
+func TestReconcileClusterPoolDeleteNoPullSecretRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Spec.PullSecretRef = nil
+
+	createDeletingClusterPool(ctx, cpr, cp)
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+
+	assert.NotPanics(t, func() {
+		_, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when clusterPool without a pull secret is deleted")
+	})
+
+	_, err := cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when provider secret was successfully deleted")
+	assert.Contains(t, err.Error(), " not found", "secret should not be found")
+}
+
+func TestReconcileClusterPoolDeleteNoInstallConfigRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Spec.InstallConfigSecretTemplateRef = nil
+
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.InstallConfigSecretTemplateRef = nil
+	sibling.Spec.Platform.GCP.CredentialsSecretRef.Name = "secret05"
+
+	createDeletingClusterPool(ctx, cpr, cp)
+	cpr.Client.Create(ctx, sibling, &client.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret02"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+
+	assert.NotPanics(t, func() {
+		_, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when clusterPool without an install-config template is deleted")
+	})
+
+	_, err := cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret02", v1.GetOptions{})
+	assert.Nil(t, err, "nil, when unreferenced install-config secret was left alone")
+
+	_, err = cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret03", v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when provider secret was successfully deleted")
+	assert.Contains(t, err.Error(), " not found", "secret should not be found")
+}
+
+func TestReconcileClusterPoolDeleteRecordsEvents(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	recorder := record.NewFakeRecorder(10)
+	cpr.Recorder = recorder
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret01"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret02"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Len(t, recorder.Events, 3, "one event per deleted secret")
+	assert.Equal(t, "Normal SecretDeleted Deleted install-config secret: secret02", <-recorder.Events)
+	assert.Equal(t, "Normal SecretDeleted Deleted pull secret: secret01", <-recorder.Events)
+	assert.Equal(t, "Normal SecretDeleted Deleted provider credential secret: secret03", <-recorder.Events)
+}
+
+func TestReconcileClusterPoolDeleteNamespaceRetainedEvent(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	recorder := record.NewFakeRecorder(10)
+	cpr.Recorder = recorder
+
+	cp := GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME, "aws")
+	cpr.Client.Create(ctx, GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	if assert.Len(t, recorder.Events, 1, "one event for the retained namespace") {
+		assert.Equal(t, "Normal NamespaceRetained Kept namespace "+CP_NAMESPACE+", it is still used by 1 other cluster pools", <-recorder.Events)
+	}
+}
+
+func TestReconcileClusterPoolDeleteUnlabeledNamespaceRetainedEvent(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	recorder := record.NewFakeRecorder(10)
+	cpr.Recorder = recorder
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME, "aws"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	if assert.Len(t, recorder.Events, 1, "one event for the retained namespace") {
+		assert.Equal(t, "Normal NamespaceRetained Kept namespace "+CP_NAMESPACE+", it does not have the "+LABEL_NAMESPACE+"="+CLUSTERPOOLS+" label", <-recorder.Events)
+	}
+}
+
+func TestDeleteResourcesNothingToCleanUp(t *testing.T) {
+
+	ctx := context.Background()
+
+	calls := 0
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			calls++
+			return c.Get(ctx, key, obj, opts...)
+		},
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			calls++
+			return c.List(ctx, list, opts...)
+		},
+	}).Build()
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), v1.CreateOptions{})
+
+	_, requeueAfter, err := deleteResources(ctx, cpr, GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME, "aws"))
+	assert.Nil(t, err, "nil, when there is nothing to clean up")
+	assert.Zero(t, requeueAfter, "no requeue, when there is nothing to clean up")
+	assert.Zero(t, calls, "no pool, claim or cluster deployment is read")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "the unlabeled namespace is kept")
+}
+
+func TestReconcileClusterPoolDeleteManagedNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when namespace was successfully deleted")
+	assert.Contains(t, err.Error(), " not found", "namespace should not be found")
+}
+
+func TestReconcileClusterPoolDeleteNamespacePropagation(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, policy := range []v1.DeletionPropagation{"", v1.DeletePropagationForeground, v1.DeletePropagationBackground} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.NamespaceDeletePropagation = policy
+
+		var options *v1.DeleteOptions
+		cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "namespaces",
+			func(action clienttesting.Action) (bool, runtime.Object, error) {
+				deleteOptions := action.(clienttesting.DeleteAction).GetDeleteOptions()
+				options = &deleteOptions
+				return false, nil, nil
+			})
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+		_, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+		if assert.NotNil(t, options, "namespace was deleted") {
+			if policy == "" {
+				assert.Nil(t, options.PropagationPolicy, "default propagation, when no policy is set")
+			} else if assert.NotNil(t, options.PropagationPolicy, "propagation policy is forwarded") {
+				assert.Equal(t, policy, *options.PropagationPolicy)
+			}
+		}
+	}
+}
+
+func TestReconcileClusterPoolDeleteNamespaceCreatedPool(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Create a cluster pool right after the cleanup counted the pools of the namespace
+	lists := 0
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&hivev1.ClusterPool{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				if err := c.List(ctx, list, opts...); err != nil {
+					return err
+				}
+				if _, ok := list.(*hivev1.ClusterPoolList); ok {
+					lists++
+					if lists == 1 {
+						return c.Create(ctx, GetClusterPool(CP_NAMESPACE, "new-pool", "aws"))
+					}
+				}
+				return nil
+			},
+		}).Build()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when the namespace deletion was aborted")
+	assert.NotContains(t, deleted, "namespace/"+CP_NAMESPACE, "the namespace is not reported deleted")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "nil, when the namespace of the new cluster pool survives")
+}
+
+func TestReconcileClusterPoolDeleteTerminatingNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("namespace is terminating")
+		})
+
+	ns := getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS})
+	ns.Finalizers = []string{"kubernetes"}
+	ns.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	ns.Status.Phase = corev1.NamespaceTerminating
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, ns, v1.CreateOptions{})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the secrets are left to the terminating namespace")
+
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret "+name+" is not deleted")
+	}
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.True(t, k8serrors.IsNotFound(err), "the finalizer is removed, so the cluster pool is deleted")
+}
+
+func TestReconcileClusterPoolTerminatingNamespaceNoFinalizer(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	ns := getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS})
+	ns.Finalizers = []string{"kubernetes"}
+	ns.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	ns.Status.Phase = corev1.NamespaceTerminating
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, ns, v1.CreateOptions{})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool is left to the terminating namespace")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Empty(t, cp.Finalizers, "no finalizer is added to a pool in a terminating namespace")
+
+	ns.DeletionTimestamp = nil
+	ns.Status.Phase = corev1.NamespaceActive
+	cpr.KubeClient.CoreV1().Namespaces().Update(ctx, ns, v1.UpdateOptions{})
+
+	_, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the finalizer is added")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Equal(t, []string{FINALIZER}, cp.Finalizers, "the finalizer is added in an active namespace")
+}
+
+func TestReconcileClusterPoolDeleteSecretNameResolver(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.SecretNameResolver = suffixResolver
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	for _, name := range []string{"secret01-prod", "secret02-prod", "secret03-prod"} {
+		secret := getSecret(CP_NAMESPACE, name)
+		secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+		cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+	}
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Equal(t, []string{"secret/secret02-prod", "secret/secret01-prod", "secret/secret03-prod", "namespace/" + CP_NAMESPACE}, deleted,
+		"the resolved secrets, and then the namespace, are deleted")
+}
+
+func TestReconcileClusterPoolDeleteNamespaceWithManagedSecret(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, value := range []string{CLUSTERPOOLS, "policies"} {
+		cpr := GetClusterPoolsReconciler()
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+		secret := getSecret(CP_NAMESPACE, "unrelated")
+		secret.Labels = map[string]string{LABEL_NAMESPACE: value}
+		cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+
+		_, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+		_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+		assert.Nil(t, err, "nil, when a secret labeled "+LABEL_NAMESPACE+"="+value+" kept the namespace")
+		assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "unrelated"), "the unrelated secret is kept")
+	}
+}
+
+func TestReconcileClusterPoolDeleteNamespaceWithRetainedSecret(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Annotations = map[string]string{RETAIN_SECRETS: SECRET_TYPE_PULL}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	secret := getSecret(CP_NAMESPACE, "secret01")
+	secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "unlabeled")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err), "the pool's own retained secret and unlabeled secrets do not keep the namespace")
+}
+
+func TestReconcileClusterPoolLabelNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.AutoLabelNamespace = true
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), v1.CreateOptions{})
+	// Created by the platform in every namespace, and the pool's own secrets
+	cpr.KubeClient.CoreV1().ConfigMaps(CP_NAMESPACE).Create(ctx, &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "kube-root-ca.crt"}}, v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "default-token-abcd"},
+		Type:       corev1.SecretTypeServiceAccountToken,
+	}, v1.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool was reconciled")
+
+	ns, _ := cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Equal(t, CLUSTERPOOLS, ns.Labels[LABEL_NAMESPACE], "the first pool's namespace is labeled")
+}
+
+func TestReconcileClusterPoolLabelNamespaceSkipped(t *testing.T) {
+
+	ctx := context.Background()
+
+	cases := map[string]func(cpr *ClusterPoolsReconciler){
+		"busy namespace": func(cpr *ClusterPoolsReconciler) {
+			seedSecrets(ctx, cpr, CP_NAMESPACE, "app-credentials")
+		},
+		"running pods": func(cpr *ClusterPoolsReconciler) {
+			cpr.KubeClient.CoreV1().Pods(CP_NAMESPACE).Create(ctx, &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "app"}}, v1.CreateOptions{})
+		},
+		"other pools": func(cpr *ClusterPoolsReconciler) {
+			cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+		},
+		"disabled": func(cpr *ClusterPoolsReconciler) {
+			cpr.AutoLabelNamespace = false
+		},
+	}
+
+	for name, setup := range cases {
+		cpr := GetClusterPoolsReconciler()
+		cpr.AutoLabelNamespace = true
+
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), v1.CreateOptions{})
+		cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+		setup(cpr)
+
+		_, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when the cluster pool was reconciled: "+name)
+
+		ns, _ := cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+		assert.NotContains(t, ns.Labels, LABEL_NAMESPACE, "the namespace is not labeled: "+name)
+	}
+}
+
+func TestLabelNamespaceSystemNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, namespace := range []string{"default", "kube-public", "openshift-config", "open-cluster-management"} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(namespace, nil), v1.CreateOptions{})
+
+		err := labelNamespace(ctx, cpr, GetClusterPool(namespace, CP_NAME, "aws"))
+		assert.Nil(t, err, "nil, when a system namespace is skipped")
+
+		ns, _ := cpr.KubeClient.CoreV1().Namespaces().Get(ctx, namespace, v1.GetOptions{})
+		assert.NotContains(t, ns.Labels, LABEL_NAMESPACE, "system namespace is not labeled: "+namespace)
+	}
+}
+
+func TestReconcileClusterPoolDeleteNamespaceGracePeriod(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, replaced := range []bool{false, true} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.NamespaceDeletionGracePeriod = time.Hour
+
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+		createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+		result, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when waiting for the grace period")
+		assert.Greater(t, result.RequeueAfter, 59*time.Minute, "requeued for the rest of the grace period")
+
+		_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+		assert.Nil(t, err, "nil, when the namespace is kept during the grace period")
+		var cp hivev1.ClusterPool
+		err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+		assert.Nil(t, err, "nil, when the finalizer holds the cluster pool during the grace period")
+
+		if replaced {
+			cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+		}
+
+		// The grace period has passed
+		cpr.NamespaceDeletionGracePeriod = time.Nanosecond
+
+		result, err = cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when the grace period has passed")
+		assert.Zero(t, result.RequeueAfter, "not requeued after the grace period")
+
+		_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+		if replaced {
+			assert.Nil(t, err, "nil, when a pool created during the grace period spared the namespace")
+		} else {
+			assert.True(t, k8serrors.IsNotFound(err), "the namespace is deleted after the grace period")
+		}
+		err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+		assert.True(t, k8serrors.IsNotFound(err), "the cluster pool is released after the grace period")
+	}
+}
+
+func TestReconcileClusterPoolOwnerRefMode(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.OwnerRefMode = true
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.UID = "uid01"
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	cp2 := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+	cp2.UID = "uid02"
+	cp2.Spec.PullSecretRef.Name = "secret11"
+	cpr.Client.Create(ctx, cp2, &client.CreateOptions{})
+
+	for _, name := range []string{CP_NAME, CP_NAME + "02"} {
+		// Reconciled twice, the owner references are not duplicated
+		for range 2 {
+			_, err := cpr.Reconcile(ctx, getRequestWithNamespaceName(CP_NAMESPACE, name))
+			assert.Nil(t, err, "nil, when the owner references were set")
+		}
+	}
+
+	owners := func(name string) []types.UID {
+		secret, _ := cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, name, v1.GetOptions{})
+		var uids []types.UID
+		for _, ref := range secret.OwnerReferences {
+			assert.Equal(t, "ClusterPool", ref.Kind, "owned by a cluster pool")
+			assert.Nil(t, ref.Controller, "not a controller reference")
+			uids = append(uids, ref.UID)
+		}
+		return uids
+	}
+	assert.Equal(t, []types.UID{"uid01"}, owners("secret01"), "the pull secret is owned by its only pool")
+	assert.Equal(t, []types.UID{"uid01", "uid02"}, owners("secret02"), "the shared install-config secret has both pools as owners")
+	assert.Equal(t, []types.UID{"uid01", "uid02"}, owners("secret03"), "the shared provider secret has both pools as owners")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "secrets are left to the garbage collector")
+}
+
+func TestReconcileClusterPoolFinalizerOnlyWithCleanup(t *testing.T) {
+
+	ctx := context.Background()
+
+	cases := []struct {
+		name     string
+		labeled  bool
+		setup    func(cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool)
+		expected bool
+	}{
+		{"unretained secrets, unlabeled namespace", false, func(cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool) {}, true},
+		{"retained secrets, unlabeled namespace", false, func(cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool) {
+			cp.Annotations = map[string]string{RETAIN_SECRETS: "pull,installconfig,provider"}
+		}, false},
+		{"retained secrets, labeled namespace", true, func(cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool) {
+			cp.Annotations = map[string]string{RETAIN_SECRETS: "pull,installconfig,provider"}
+		}, true},
+		{"owner references, unlabeled namespace", false, func(cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool) {
+			cpr.OwnerRefMode = true
+		}, false},
+		{"owner references, labeled namespace", true, func(cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool) {
+			cpr.OwnerRefMode = true
+		}, true},
+		{"owner references, labeled namespace retained by the pool", true, func(cpr *ClusterPoolsReconciler, cp *hivev1.ClusterPool) {
+			cpr.OwnerRefMode = true
+			cp.Annotations = map[string]string{RETAIN_NAMESPACE: "true"}
+		}, false},
+	}
+
+	for _, c := range cases {
+		cpr := GetClusterPoolsReconciler()
+
+		var nsLabels map[string]string
+		if c.labeled {
+			nsLabels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+		}
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nsLabels), v1.CreateOptions{})
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		c.setup(cpr, cp)
+		cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+
+		_, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when the cluster pool was reconciled: "+c.name)
+
+		cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+		assert.Equal(t, c.expected, controllerutil.ContainsFinalizer(cp, FINALIZER), "finalizer added: "+c.name)
+	}
+}
+
+func TestReconcileClusterPoolDeleteUnmanagedNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "nil, when namespace without the managed-by label was retained")
+}
+
+func TestReconcileClusterPoolDeleteNamespaceWithOtherPools(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp"), &client.CreateOptions{})
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "nil, when namespace still holding cluster pools was retained")
+}
+
+func TestReconcileClusterPoolDeleteCustomNamespaceLabel(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.NamespaceLabel = "example.com/owner"
+	cpr.NamespaceLabelValue = "pool-controller"
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{"example.com/owner": "pool-controller"}), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace("default-labeled", map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when namespace with the custom label was deleted")
+	assert.Contains(t, err.Error(), " not found", "namespace should not be found")
+
+	_, _, err = deleteResources(ctx, cpr, GetClusterPool("default-labeled", CP_NAME, "aws"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, "default-labeled", v1.GetOptions{})
+	assert.Nil(t, err, "nil, when namespace with only the default label was retained")
+}
+
+func TestReconcileClusterPoolDeleteRetainNamespaceOnPool(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Annotations = map[string]string{RETAIN_NAMESPACE: "true"}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "nil, when namespace was retained by the pool annotation")
+}
+
+func TestReconcileClusterPoolDeleteRetainNamespaceOnNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	ns := getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS})
+	ns.Annotations = map[string]string{RETAIN_NAMESPACE: "true"}
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, ns, v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "nil, when namespace was retained by its own annotation")
+}
+
+func TestReconcileClusterPoolDeleteProtectedNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, namespace := range []string{"kube-tenant", "openshift-pools", "prod-east"} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.ProtectedNamespaces = []string{"prod-*"}
+		recorder := record.NewFakeRecorder(10)
+		cpr.Recorder = recorder
+
+		cp := GetClusterPool(namespace, CP_NAME, "aws")
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(namespace, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+		_, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful in "+namespace)
+
+		_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, namespace, v1.GetOptions{})
+		assert.Nil(t, err, "nil, when the protected namespace "+namespace+" was retained")
+
+		if assert.Len(t, recorder.Events, 1, "one event for the protected namespace "+namespace) {
+			assert.Equal(t, "Normal NamespaceRetained Kept namespace "+namespace+", it is protected", <-recorder.Events)
+		}
+	}
+}
+
+func TestReconcileClusterPoolDeleteNamespaceWithWorkloads(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, workload := range []string{"", "pod", "persistentvolumeclaim"} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.CheckNamespaceEmptyBeforeDelete = true
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+		switch workload {
+		case "pod":
+			cpr.KubeClient.CoreV1().Pods(CP_NAMESPACE).Create(ctx, &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "dev-workload"}}, v1.CreateOptions{})
+		case "persistentvolumeclaim":
+			cpr.KubeClient.CoreV1().PersistentVolumeClaims(CP_NAMESPACE).Create(ctx, &corev1.PersistentVolumeClaim{ObjectMeta: v1.ObjectMeta{Name: "dev-data"}}, v1.CreateOptions{})
+		}
+
+		_, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+		if workload == "" {
+			assert.False(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the namespace without workloads is deleted")
+		} else {
+			assert.True(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the namespace with a "+workload+" is kept")
+		}
+	}
+}
+
+func TestReconcileClusterPoolDeleteNamespaceWorkloadsNotChecked(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Pods(CP_NAMESPACE).Create(ctx, &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "dev-workload"}}, v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the pods are not checked unless CheckNamespaceEmptyBeforeDelete is set")
+}
+
+func TestDeleteNamespacePoolCreatedKeepsManagedSecrets(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ManagedSecretLabels = map[string]string{"tooling.example.com/auxiliary": "true"}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+	secret := getSecret(CP_NAMESPACE, "pool-proxy-ca")
+	secret.Labels = map[string]string{"tooling.example.com/auxiliary": "true", LABEL_NAMESPACE: CLUSTERPOOLS}
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+
+	deleted, err := deleteNamespace(ctx, cpr, cp, nil)
+	assert.Nil(t, err, "nil, when the namespace deletion was aborted")
+	assert.Empty(t, deleted, "nothing is deleted once a cluster pool was created in the namespace")
+	assert.True(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the namespace of the new cluster pool is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "pool-proxy-ca"), "the auxiliary secrets the new cluster pool may need are kept")
+}
+
+func TestReconcileClusterPoolDeleteNamespaceWithWorkloadsKeepsManagedSecrets(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.CheckNamespaceEmptyBeforeDelete = true
+	cpr.ManagedSecretLabels = map[string]string{"tooling.example.com/auxiliary": "true"}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Pods(CP_NAMESPACE).Create(ctx, &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "dev-workload"}}, v1.CreateOptions{})
+	secret := getSecret(CP_NAMESPACE, "pool-proxy-ca")
+	secret.Labels = map[string]string{"tooling.example.com/auxiliary": "true"}
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.True(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the namespace with a pod is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "pool-proxy-ca"), "the auxiliary secrets of the kept namespace are kept")
+}
+
+func TestIsProtectedNamespace(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ProtectedNamespaces = []string{"prod-*", "shared"}
+
+	for namespace, protected := range map[string]bool{
+		"kube-system":      true,
+		"openshift-config": true,
+		"prod-east":        true,
+		"shared":           true,
+		"shared-pools":     false,
+		"kubeflow":         false,
+		CP_NAMESPACE:       false,
+	} {
+		assert.Equal(t, protected, isProtectedNamespace(cpr, namespace), "protection of "+namespace)
+	}
+}
+
+func TestReconcileClusterPoolDeleteRetainSecrets(t *testing.T) {
+
+	ctx := context.Background()
+
+	// secret01: pull, secret02: install-config, secret03: provider, secret04: certificates
+	retained := map[string][]string{
+		"pull":                         {"secret01"},
+		"installconfig":                {"secret02"},
+		"provider":                     {"secret03", "secret04"},
+		"pull, installconfig,provider": {"secret01", "secret02", "secret03", "secret04"},
+		"":                             {},
+	}
+
+	for annotation, keep := range retained {
+		cpr := GetClusterPoolsReconciler()
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+		cp.Annotations = map[string]string{RETAIN_SECRETS: annotation}
+
+		seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03", "secret04")
+
+		_, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+		for _, name := range []string{"secret01", "secret02", "secret03", "secret04"} {
+			assert.Equal(t, slices.Contains(keep, name), secretExists(ctx, cpr, CP_NAMESPACE, name),
+				"retain-secrets: \""+annotation+"\", secret: "+name)
+		}
+	}
+}
+
+func TestReconcileClusterPoolDeleteCrossNamespaceRefCounting(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.CrossNamespaceRefCounting = true
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool("other-pools", CP_NAME, "aws"), &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "pull secret referenced in another namespace is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "install-config secret referenced in another namespace is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "provider secret referenced in another namespace is kept")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.NotNil(t, err, "not nil, when the last pool in the namespace removed it")
+}
+
+func TestReconcileClusterPoolDeleteNamespacedRefCounting(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool("other-pools", CP_NAME, "aws"), &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "pools in other namespaces are ignored by default")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "pools in other namespaces are ignored by default")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "pools in other namespaces are ignored by default")
+}
+
+func TestReconcileClusterPoolDeleteEventWithoutFinalizer(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	// The pool was removed without this controller's finalizer, so no deletion reconcile ran
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	assert.True(t, eventFilter(cpr).Delete(event.DeleteEvent{Object: cp}), "delete events are reconciled")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when cleanup from the deleted pool's last state was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "pull secret is cleaned up")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "install-config secret is cleaned up")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "provider secret is cleaned up")
+}
+
+func TestReconcileClusterPoolDeleteEventWithFinalizer(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when clusterPool delete reconcile successful")
+
+	// Secrets recreated after the cleanup ran must not be deleted a second time by the delete event
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	assert.True(t, eventFilter(cpr).Delete(event.DeleteEvent{Object: cp}), "delete events are reconciled")
+
+	_, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the deleted pool was already cleaned up")
+
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "cleanup does not run twice")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "cleanup does not run twice")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "cleanup does not run twice")
+}
+
+func TestEventFilterWatchLabelSelector(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	filter := eventFilter(cpr)
+
+	matching := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	matching.Labels = map[string]string{"console": "true"}
+	other := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+
+	assert.True(t, filter.Create(event.CreateEvent{Object: other}), "all pools are watched without a selector")
+
+	cpr.WatchLabelSelector = labels.SelectorFromSet(labels.Set{"console": "true"})
+
+	assert.True(t, filter.Create(event.CreateEvent{Object: matching}), "a matching pool is created")
+	finalized := matching.DeepCopy()
+	finalized.Finalizers = []string{FINALIZER}
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: matching, ObjectNew: finalized}), "a matching pool is updated")
+	assert.True(t, filter.Delete(event.DeleteEvent{Object: matching}), "a matching pool is deleted")
+
+	assert.False(t, filter.Create(event.CreateEvent{Object: other}), "a pool without the label is ignored")
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: other, ObjectNew: other}), "a pool without the label is ignored")
+	assert.False(t, filter.Delete(event.DeleteEvent{Object: other}), "a pool without the label is ignored")
+
+	_, found := cpr.tombstones.Load(client.ObjectKeyFromObject(other))
+	assert.False(t, found, "no tombstone is kept for an ignored pool")
+
+	unlabeled := matching.DeepCopy()
+	unlabeled.Labels = nil
+	unlabeled.Finalizers = []string{FINALIZER}
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: matching, ObjectNew: unlabeled}),
+		"a pool that lost the label keeps being watched while it has the finalizer")
+}
+
+func TestEventFilterUpdate(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	filter := eventFilter(cpr)
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+
+	touched := cp.DeepCopy()
+	touched.Labels = map[string]string{"hive": "touched"}
+	touched.Annotations = map[string]string{"hive": "touched"}
+	touched.Spec.Size = 5
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: cp, ObjectNew: touched}),
+		"an update that keeps the finalizer and deletion timestamp is ignored")
+
+	deleting := cp.DeepCopy()
+	now := v1.Now()
+	deleting.DeletionTimestamp = &now
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: cp, ObjectNew: deleting}),
+		"an update setting the deletion timestamp is reconciled")
+
+	stripped := cp.DeepCopy()
+	stripped.Finalizers = nil
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: cp, ObjectNew: stripped}),
+		"an update removing the finalizer is reconciled, so the finalizer is added back")
+
+	unpaused := cp.DeepCopy()
+	cp.Annotations = map[string]string{PAUSED: "true"}
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: cp, ObjectNew: unpaused}),
+		"an update removing the paused annotation is reconciled")
+
+	for _, annotation := range []string{RETAIN_SECRETS, RETAIN_NAMESPACE} {
+		retained := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		retained.Annotations = map[string]string{annotation: "true"}
+		released := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: retained, ObjectNew: released}),
+			"an update removing the "+annotation+" annotation is reconciled, so the pool gets the finalizer")
+	}
+
+	cpr.WatchLabelSelector = labels.SelectorFromSet(labels.Set{"console": "true"})
+	unwatched := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	labeled := unwatched.DeepCopy()
+	labeled.Labels = map[string]string{"console": "true"}
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: unwatched, ObjectNew: labeled}),
+		"an update adding the watched label is reconciled, so the pool gets the finalizer")
+}
+
+func TestReconcileClusterPoolWatchLabelSelector(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.WatchLabelSelector = labels.SelectorFromSet(labels.Set{"console": "true"})
+
+	matching := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	matching.Labels = map[string]string{"console": "true"}
+	cpr.Client.Create(ctx, matching, &client.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the matching pool was reconciled")
+	_, err = cpr.Reconcile(ctx, getRequestWithNamespaceName(CP_NAMESPACE, CP_NAME+"02"))
+	assert.Nil(t, err, "nil, when the pool without the label was skipped")
+
+	var cp hivev1.ClusterPool
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	assert.Equal(t, []string{FINALIZER}, cp.Finalizers, "the matching pool gets the finalizer")
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME+"02"), &cp)
+	assert.Empty(t, cp.Finalizers, "the pool without the label never gets the finalizer")
+}
+
+func TestReconcileClusterPoolNamespaceScoped(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.Namespace = CP_NAMESPACE
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+	other := GetClusterPool("tenant02", CP_NAME, "aws")
+	other.Finalizers = []string{FINALIZER}
+	cpr.Client.Create(ctx, other, &client.CreateOptions{})
+
+	filter := eventFilter(cpr)
+	assert.False(t, filter.Create(event.CreateEvent{Object: other}), "a pool in another namespace is ignored")
+	assert.False(t, filter.Delete(event.DeleteEvent{Object: other}), "a pool in another namespace is ignored, even with the finalizer")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool in the namespace was reconciled")
+	_, err = cpr.Reconcile(ctx, getRequestWithNamespaceName("tenant02", CP_NAME))
+	assert.Nil(t, err, "nil, when the pool in another namespace was skipped")
+
+	var cp hivev1.ClusterPool
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	assert.Equal(t, []string{FINALIZER}, cp.Finalizers, "the pool in the namespace gets the finalizer")
+
+	// The pool in the other namespace shares the secret names, but does not count with a scoped controller
+	cpr.CrossNamespaceRefCounting = true
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	_, _, err = deleteResources(ctx, cpr, &cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "references from outside the namespace are not counted")
+}
+
+func TestApplyNamespace(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+
+	var options ctrl.Options
+	cpr.ApplyNamespace(&options)
+	assert.Nil(t, options.Cache.DefaultNamespaces, "the cache is cluster-wide without a namespace")
+
+	cpr.Namespace = CP_NAMESPACE
+	cpr.ApplyNamespace(&options)
+	assert.Contains(t, options.Cache.DefaultNamespaces, CP_NAMESPACE, "the cache is scoped to the namespace")
+	assert.Len(t, options.Cache.DefaultNamespaces, 1, "the cache watches only the namespace")
+}
+
+func TestReconcileClusterPoolDeleteCleanupConditions(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{"hive.openshift.io/test"}
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01")
+
+	// Capture the pool status while the cleanup is deleting secrets
+	var inProgress *hivev1.ClusterPoolCondition
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			var current hivev1.ClusterPool
+			cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &current)
+			inProgress = getPoolCondition(&current, CONDITION_CLEANUP_COMPLETED)
+			return false, nil, nil
+		})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when clusterPool delete reconcile successful")
+
+	if assert.NotNil(t, inProgress, "condition is set while cleaning up") {
+		assert.Equal(t, corev1.ConditionFalse, inProgress.Status)
+		assert.Equal(t, "InProgress", inProgress.Reason)
+	}
+
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Nil(t, err, "the pool is kept by the remaining finalizer")
+
+	condition := getPoolCondition(cp, CONDITION_CLEANUP_COMPLETED)
+	if assert.NotNil(t, condition, "condition is set after cleanup") {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status)
+		assert.Equal(t, "Completed", condition.Reason)
+	}
+	assert.Nil(t, getPoolCondition(cp, CONDITION_CLEANUP_FAILED), "no failure was recorded")
+}
+
+func TestReconcileClusterPoolDeleteCleanupFailedCondition(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("apiserver unavailable")
+		})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.NotNil(t, err, "not nil, when the cleanup failed")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+
+	condition := getPoolCondition(cp, CONDITION_CLEANUP_FAILED)
+	if assert.NotNil(t, condition, "failure condition is set") {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status)
+		assert.Contains(t, condition.Message, "apiserver unavailable")
+	}
+	assert.True(t, controllerutil.ContainsFinalizer(cp, FINALIZER), "finalizer is kept for a retry")
+}
+
+func TestIsRetryable(t *testing.T) {
+
+	secrets := schema.GroupResource{Resource: "secrets"}
+
+	assert.True(t, isRetryable(k8serrors.NewConflict(secrets, "secret01", errors.New("modified"))), "conflict is retryable")
+	assert.True(t, isRetryable(k8serrors.NewServerTimeout(secrets, "get", 1)), "server timeout is retryable")
+	assert.True(t, isRetryable(k8serrors.NewTooManyRequests("slow down", 1)), "throttling is retryable")
+	assert.False(t, isRetryable(k8serrors.NewForbidden(secrets, "secret01", errors.New("denied"))), "forbidden is not retryable")
+	assert.False(t, isRetryable(errors.New("unexpected")), "unknown errors are not retryable")
+}
+
+func TestReconcileClusterPoolDeletePullSecretFails(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.(clienttesting.DeleteAction).GetName() == "secret01" {
+				return true, nil, errors.New("delete failed")
+			}
+			return false, nil, nil
+		})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	var secretErr *ErrSecretDeletionFailed
+	assert.ErrorAs(t, err, &secretErr, "the failed pull secret deletion is returned")
+
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "the pull secret failed to delete")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "the install-config secret is still deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the provider secret is still deleted")
+
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "the namespace is kept while a secret failed to delete")
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.True(t, controllerutil.ContainsFinalizer(cp, FINALIZER), "the finalizer is kept for a retry")
+}
+
+func TestDeleteResourcesMinPoolAgeForCleanup(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, age := range []time.Duration{time.Second, time.Hour} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.MinPoolAgeForCleanup = 10 * time.Minute
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		cp.CreationTimestamp = v1.NewTime(time.Now().Add(-age))
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+		seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+		deleted, requeueAfter, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+		assert.Zero(t, requeueAfter, "the cleanup is not requeued, the finalizer can be removed")
+
+		if age < cpr.MinPoolAgeForCleanup {
+			assert.Empty(t, deleted, "nothing is deleted for a pool younger than the minimum age")
+			assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "the secrets of a young pool are kept")
+		} else {
+			assert.Len(t, deleted, 3, "the secrets of an old pool are deleted")
+			assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "the secrets of an old pool are deleted")
+		}
+	}
+}
+
+func TestDeleteResourcesCleanupTimeBudget(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.CleanupTimeBudget = time.Nanosecond
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	var deletes []string
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			deletes = append(deletes, action.(clienttesting.DeleteAction).GetName())
+			return false, nil, nil
+		})
+
+	deleted, requeueAfter, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when the cleanup is continued later")
+	assert.Equal(t, CLEANUP_BUDGET_REQUEUE, requeueAfter, "requeued once the budget is spent")
+	assert.ElementsMatch(t, []string{"secret/secret01", "secret/secret02", "secret/secret03"}, deleted, "the pool secrets are deleted within the budget")
+	assert.True(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the namespace waits for the next pass")
+
+	deletes = nil
+	cpr.CleanupTimeBudget = time.Hour
+
+	deleted, requeueAfter, err = deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when the cleanup is finished")
+	assert.Zero(t, requeueAfter, "no requeue, once the cleanup is finished")
+	assert.Equal(t, []string{"namespace/" + CP_NAMESPACE}, deleted, "the next pass finishes with the namespace")
+	assert.NotContains(t, deletes, "secret01", "the secrets deleted by the first pass are not deleted again")
+	assert.False(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the namespace is deleted")
+
+	_, found := cpr.cleanupProgress.Load(client.ObjectKeyFromObject(cp))
+	assert.False(t, found, "the progress is forgotten once the cleanup is finished")
+}
+
+func TestDeleteResourcesDeleteRetries(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, retries := range []int{0, 1, 2} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.DeleteRetries = retries
+
+		// Fail the first two attempts to delete the pull secret
+		attempts := 0
+		cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+			func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if action.(clienttesting.DeleteAction).GetName() != "secret01" {
+					return false, nil, nil
+				}
+				attempts++
+				if attempts <= 2 {
+					return true, nil, k8serrors.NewServerTimeout(schema.GroupResource{Resource: "secrets"}, "delete", 1)
+				}
+				return false, nil, nil
+			})
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+		seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+		_, _, err := deleteResources(ctx, cpr, cp)
+
+		if retries < 2 {
+			assert.NotNil(t, err, "not nil, when the deletion still fails after the retries")
+			assert.Equal(t, retries+1, attempts, "the deletion is attempted once plus the retries")
+			assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "the secret is kept")
+		} else {
+			assert.Nil(t, err, "nil, when the third attempt deletes the secret")
+			assert.Equal(t, 3, attempts, "the secret is deleted on the third attempt")
+			assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "the secret is deleted")
+		}
+	}
+}
+
+func TestReconcileClusterPoolDeleteConflictBackoff(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "secret01", errors.New("modified"))
+		})
+
+	createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	var previous time.Duration
+	for i := 0; i < 3; i++ {
+		result, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when a retryable error is requeued")
+		assert.Greater(t, result.RequeueAfter, previous, "requeue delay grows with each retry")
+		previous = result.RequeueAfter
+	}
+}
+
+// conflictingPatch fails every cluster pool patch with the given error
+func conflictingPatch(patchErr error) interceptor.Funcs {
+	return interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return patchErr
+		},
+	}
+}
+
+func TestReconcileClusterPoolFinalizerConflict(t *testing.T) {
+
+	ctx := context.Background()
+
+	conflict := k8serrors.NewConflict(schema.GroupResource{Group: "hive.openshift.io", Resource: "clusterpools"}, CP_NAME, errors.New("modified"))
+	cpr := getHungClusterPoolsReconciler(conflictingPatch(conflict), GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	cpr.ClientTimeout = 0
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the finalizer patch conflicts")
+	assert.GreaterOrEqual(t, result.RequeueAfter, BACKOFF_BASE_DELAY, "a conflicting finalizer patch is requeued after the backoff")
+	assert.LessOrEqual(t, result.RequeueAfter, time.Duration(float64(BACKOFF_BASE_DELAY)*(1+BACKOFF_JITTER_FACTOR)), "with at most the jitter added")
+
+	deleting := GetClusterPool(CP_NAMESPACE, CP_NAME, "none")
+	deleting.Spec.PullSecretRef = nil
+	deleting.Spec.InstallConfigSecretTemplateRef = nil
+	deleting.Finalizers = []string{FINALIZER}
+	deleting.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cpr = GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&hivev1.ClusterPool{}).
+		WithObjects(deleting).WithInterceptorFuncs(conflictingPatch(conflict)).Build()
+
+	err = removeFinalizer(ctx, cpr, deleting.DeepCopy())
+	assert.True(t, k8serrors.IsConflict(err), "removeFinalizer reports the conflict")
+	result, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the finalizer removal conflicts")
+	assert.Greater(t, result.RequeueAfter, time.Duration(0), "a conflicting finalizer removal is requeued")
+}
+
+// conflictingPatches fails the first patches with a conflict, and passes the later ones through
+func conflictingPatches(conflicts int) interceptor.Funcs {
+	conflict := k8serrors.NewConflict(schema.GroupResource{Group: "hive.openshift.io", Resource: "clusterpools"}, CP_NAME, errors.New("modified"))
+	return interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			if conflicts > 0 {
+				conflicts--
+				return conflict
+			}
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	}
+}
+
+func TestRemoveFinalizerConflictRetried(t *testing.T) {
+
+	ctx := context.Background()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithObjects(cp.DeepCopy()).WithInterceptorFuncs(conflictingPatches(2)).Build()
+	recorder := record.NewFakeRecorder(10)
+	cpr.Recorder = recorder
+
+	err := removeFinalizer(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when the finalizer is removed once the conflicts are over")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Empty(t, cp.Finalizers, "the finalizer was removed")
+	assert.Empty(t, recorder.Events, "no event, when the finalizer was removed")
+}
+
+func TestRemoveFinalizerStalePool(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+
+	// Another actor adds its finalizer after the pool was read
+	latest := &hivev1.ClusterPool{}
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), latest)
+	latest.Finalizers = append(latest.Finalizers, "example.com/other")
+	cpr.Client.Update(ctx, latest)
+
+	err := removeFinalizer(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when the finalizer is removed from the latest state of the pool")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), latest)
+	assert.Equal(t, []string{"example.com/other"}, latest.Finalizers, "the finalizer added meanwhile is kept")
+}
+
+func TestRemoveFinalizerConflictRetriesExhausted(t *testing.T) {
+
+	ctx := context.Background()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithObjects(cp.DeepCopy()).WithInterceptorFuncs(conflictingPatches(100)).Build()
+	recorder := record.NewFakeRecorder(10)
+	cpr.Recorder = recorder
+
+	err := removeFinalizer(ctx, cpr, cp)
+	assert.True(t, k8serrors.IsConflict(err), "removeFinalizer reports the conflict once the retries are exhausted")
+	var finalizerErr *ErrFinalizerUpdateFailed
+	assert.ErrorAs(t, err, &finalizerErr, "the failure names the cluster pool")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Equal(t, []string{FINALIZER}, cp.Finalizers, "the finalizer is kept")
+
+	if assert.Len(t, recorder.Events, 1, "one event for the stuck finalizer") {
+		event := <-recorder.Events
+		assert.True(t, strings.HasPrefix(event, "Warning "+REASON_FINALIZER_REMOVAL_FAILED+" Failed to remove finalizer "+FINALIZER+" after 5 attempts"),
+			"the warning describes the stuck finalizer: "+event)
+	}
+}
+
+func TestReconcileClusterPoolFinalizerPatchError(t *testing.T) {
+
+	ctx := context.Background()
+
+	forbidden := k8serrors.NewForbidden(schema.GroupResource{Group: "hive.openshift.io", Resource: "clusterpools"}, CP_NAME, errors.New("denied"))
+	cpr := getHungClusterPoolsReconciler(conflictingPatch(forbidden), GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	cpr.ClientTimeout = 0
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.True(t, k8serrors.IsForbidden(err), "a non-conflict patch failure is returned")
+	assert.Zero(t, result.RequeueAfter, "a non-conflict patch failure is not requeued after a delay")
+}
+
+func TestReconcileClusterPoolDeleteUnexpectedError(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("unexpected")
+		})
+
+	createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.NotNil(t, err, "not nil, when the error is not retryable")
+	assert.Zero(t, result.RequeueAfter, "unexpected errors are returned as is")
+}
+
+func TestControllerOptionsConcurrency(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	assert.Equal(t, 1, controllerOptions(cpr).MaxConcurrentReconciles, "defaults to a single worker")
+
+	cpr.Concurrency = 8
+	assert.Equal(t, 8, controllerOptions(cpr).MaxConcurrentReconciles, "configured concurrency is passed through")
+}
+
+func TestReconcileClusterPoolFinalizerNames(t *testing.T) {
+
+	ctx := context.Background()
+
+	stable := GetClusterPoolsReconciler()
+	canary := GetClusterPoolsReconciler()
+	canary.Client = stable.Client
+	canary.KubeClient = stable.KubeClient
+	canary.FinalizerName = "canary.clusterpools-controller.open-cluster-management.io/cleanup"
+
+	stable.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+
+	_, err := stable.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the stable finalizer was added")
+	_, err = canary.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the canary finalizer was added")
+
+	var cp hivev1.ClusterPool
+	stable.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	assert.ElementsMatch(t, []string{FINALIZER, canary.FinalizerName}, cp.Finalizers, "each reconciler adds its own finalizer")
+
+	stable.Client.Delete(ctx, &cp)
+
+	_, err = canary.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the canary finalizer was removed")
+
+	err = stable.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	assert.Nil(t, err, "the pool is kept by the stable finalizer")
+	assert.Equal(t, []string{FINALIZER}, cp.Finalizers, "only the canary finalizer was removed")
+
+	_, err = stable.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the stable finalizer was removed")
+
+	err = stable.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	assert.True(t, k8serrors.IsNotFound(err), "the pool is gone once both finalizers are removed")
+}
+
+func TestDeleteResourcesWaitForClusterDeployments(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.WaitForClusterDeployments = true
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cd := &hivev1.ClusterDeployment{
+		ObjectMeta: v1.ObjectMeta{Name: "cluster01", Namespace: CP_NAMESPACE},
+		Spec: hivev1.ClusterDeploymentSpec{
+			Platform: hivev1.Platform{AWS: &aws.Platform{CredentialsSecretRef: corev1.LocalObjectReference{Name: "secret03"}}},
+		},
+	}
+	cpr.Client.Create(ctx, cd, &client.CreateOptions{})
+
+	deleted, requeueAfter, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when the provider secret waits for the cluster deployment")
+	assert.Equal(t, DEPROVISION_REQUEUE, requeueAfter, "requeued while the cluster deployment remains")
+	assert.ElementsMatch(t, []string{"secret/secret01", "secret/secret02"}, deleted, "the pull and install-config secrets are deleted right away")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the provider secret is kept for the deprovision")
+	assert.True(t, namespaceExists(ctx, cpr, CP_NAMESPACE), "the namespace is kept with the provider secret")
+
+	cpr.Client.Delete(ctx, cd)
+
+	deleted, requeueAfter, err = deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Zero(t, requeueAfter, "no requeue, once the cluster deployment is gone")
+	assert.ElementsMatch(t, []string{"secret/secret03", "namespace/" + CP_NAMESPACE}, deleted, "the provider secret and the namespace are deleted")
+}
+
+func TestDeleteResourcesAfterDeleteHooks(t *testing.T) {
+
+	ctx := context.Background()
+
+	var secrets, namespaces []string
+	cpr := GetClusterPoolsReconciler()
+	cpr.AfterSecretDelete = func(name string) { secrets = append(secrets, name) }
+	cpr.AfterNamespaceDelete = func(name string) { namespaces = append(namespaces, name) }
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cd := getClusterDeployment("cluster01", CP_NAME, "")
+	cpr.Client.Create(ctx, cd, &client.CreateOptions{})
+	secret := getSecret(cd.Namespace, "cluster01-admin-kubeconfig")
+	secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+	cpr.KubeClient.CoreV1().Secrets(cd.Namespace).Create(ctx, secret, v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.ElementsMatch(t, []string{"secret01", "secret02", "secret03", "cluster01/cluster01-admin-kubeconfig"}, secrets,
+		"AfterSecretDelete is called for every deleted secret")
+	assert.Equal(t, []string{CP_NAMESPACE}, namespaces, "AfterNamespaceDelete is called for the deleted namespace")
+}
+
+func TestReconcileClusterPoolDeleteClusterDeploymentSecrets(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	managed := map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+	for _, cd := range []*hivev1.ClusterDeployment{
+		getClusterDeployment("cluster01", CP_NAME, ""),
+		getClusterDeployment("cluster02", CP_NAME, ""),
+		getClusterDeployment("cluster03", CP_NAME, "my-claim"),
+		getClusterDeployment("cluster04", CP_NAME+"02", ""),
+	} {
+		cpr.Client.Create(ctx, cd, &client.CreateOptions{})
+		for _, name := range []string{cd.Name + "-admin-kubeconfig", cd.Name + "-admin-password"} {
+			secret := getSecret(cd.Namespace, name)
+			secret.Labels = managed
+			cpr.KubeClient.CoreV1().Secrets(cd.Namespace).Create(ctx, secret, v1.CreateOptions{})
+		}
+	}
+	cpr.KubeClient.CoreV1().Secrets("cluster02").Delete(ctx, "cluster02-admin-password", v1.DeleteOptions{})
+	seedSecrets(ctx, cpr, "cluster02", "cluster02-admin-password")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, "cluster01", "cluster01-admin-kubeconfig"), "managed kubeconfig secret is deleted")
+	assert.False(t, secretExists(ctx, cpr, "cluster01", "cluster01-admin-password"), "managed password secret is deleted")
+	assert.False(t, secretExists(ctx, cpr, "cluster02", "cluster02-admin-kubeconfig"), "managed kubeconfig secret is deleted")
+	assert.True(t, secretExists(ctx, cpr, "cluster02", "cluster02-admin-password"), "unlabeled secret is kept")
+	assert.True(t, secretExists(ctx, cpr, "cluster03", "cluster03-admin-kubeconfig"), "claimed cluster secrets are kept")
+	assert.True(t, secretExists(ctx, cpr, "cluster04", "cluster04-admin-kubeconfig"), "other pool's cluster secrets are kept")
+}
+
+func TestReconcileClusterPoolDeleteReturnsDeleted(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	// secret02 is missing, so it is not reported as deleted
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret03", "secret04")
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	cd := getClusterDeployment("cluster01", CP_NAME, "")
+	cpr.Client.Create(ctx, cd, &client.CreateOptions{})
+	kubeconfig := getSecret("cluster01", "cluster01-admin-kubeconfig")
+	kubeconfig.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+	cpr.KubeClient.CoreV1().Secrets("cluster01").Create(ctx, kubeconfig, v1.CreateOptions{})
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.Equal(t, []string{
+		"secret/secret01",
+		"secret/secret03",
+		"secret/secret04",
+		"secret/cluster01/cluster01-admin-kubeconfig",
+		"namespace/" + CP_NAMESPACE,
+	}, deleted, "the deleted resources are returned")
+
+	for _, name := range []string{"secret01", "secret03", "secret04"} {
+		assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "returned secret is gone: "+name)
+	}
+	assert.False(t, secretExists(ctx, cpr, "cluster01", "cluster01-admin-kubeconfig"), "returned kubeconfig secret is gone")
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err), "returned namespace is gone")
+}
+
+func TestReconcileClusterPoolDeleteSharedReturnsNothing(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Empty(t, deleted, "nothing is deleted while another pool shares the secrets")
+}
+
+func TestReconcileClusterPoolDeleteManagedSecretLabels(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ManagedSecretLabels = map[string]string{"tooling.example.com/auxiliary": "true"}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	for _, name := range []string{"pool-proxy-ca", "pool-trust-bundle"} {
+		secret := getSecret(CP_NAMESPACE, name)
+		secret.Labels = map[string]string{"tooling.example.com/auxiliary": "true", LABEL_NAMESPACE: CLUSTERPOOLS}
+		cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+	}
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "unlabeled"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.Contains(t, deleted, "secret/pool-proxy-ca", "the labeled proxy CA secret is deleted with the namespace")
+	assert.Contains(t, deleted, "secret/pool-trust-bundle", "the labeled trust bundle secret is deleted with the namespace")
+	assert.Contains(t, deleted, "namespace/"+CP_NAMESPACE, "the namespace is no longer blocked by the auxiliary secrets")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "pool-proxy-ca"), "the labeled proxy CA secret is gone")
+}
+
+func TestReconcileClusterPoolDeleteManagedSecretLabelsUnlabeledNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ManagedSecretLabels = map[string]string{"tooling.example.com/auxiliary": "true"}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	secret := getSecret(CP_NAMESPACE, "pool-proxy-ca")
+	secret.Labels = map[string]string{"tooling.example.com/auxiliary": "true"}
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), v1.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "pool-proxy-ca"), "auxiliary secrets are kept when the namespace is not torn down")
+}
+
+func TestReconcileClusterPoolDeleteBatch(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.BatchDelete = true
+	addDeleteCollectionReactor(cpr.KubeClient.(*kubefake.Clientset))
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		seedLabeledSecret(ctx, cpr, CP_NAMESPACE, name, time.Hour)
+	}
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Contains(t, deleted, "secret/secret01", "the labeled secrets of the last pool are deleted")
+	assert.Contains(t, deleted, "namespace/"+CP_NAMESPACE, "the namespace is deleted")
+
+	deleteCollections := 0
+	for _, action := range cpr.KubeClient.(*kubefake.Clientset).Actions() {
+		if action.GetVerb() == "delete-collection" {
+			deleteCollections++
+		}
+	}
+	assert.Equal(t, 1, deleteCollections, "the labeled secrets are deleted with a single DeleteCollection")
+}
+
+func TestReconcileClusterPoolDeleteBatchOrphan(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.BatchDelete = true
+	addDeleteCollectionReactor(cpr.KubeClient.(*kubefake.Clientset))
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan", time.Hour)
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.NotContains(t, deleted, "namespace/"+CP_NAMESPACE, "the labeled orphan secret keeps the namespace")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "orphan"), "the labeled orphan secret is kept with the namespace")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the secrets of the pool are deleted one by one")
+	for _, action := range cpr.KubeClient.(*kubefake.Clientset).Actions() {
+		assert.NotEqual(t, "delete-collection", action.GetVerb(), "no DeleteCollection when the namespace is kept")
+	}
+}
+
+func TestReconcileClusterPoolDeleteBatchKeptNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	ns := getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS})
+	ns.Annotations = map[string]string{RETAIN_NAMESPACE: "true"}
+
+	for name, setup := range map[string]func(cpr *ClusterPoolsReconciler){
+		"retained": func(cpr *ClusterPoolsReconciler) {
+			cpr.KubeClient.CoreV1().Namespaces().Create(ctx, ns, v1.CreateOptions{})
+		},
+		"protected": func(cpr *ClusterPoolsReconciler) {
+			cpr.ProtectedNamespaces = []string{CP_NAMESPACE}
+			cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+		},
+	} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.BatchDelete = true
+		addDeleteCollectionReactor(cpr.KubeClient.(*kubefake.Clientset))
+		setup(cpr)
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+		seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret03", time.Hour)
+		seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "other", time.Hour)
+
+		deleted, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+		assert.NotContains(t, deleted, "namespace/"+CP_NAMESPACE, "the "+name+" namespace is kept")
+		assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "other"), "the "+name+" namespace keeps its labeled secrets")
+		assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the secrets of the pool are still deleted")
+	}
+}
+
+func TestReconcileClusterPoolDeleteBatchShared(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.BatchDelete = true
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Empty(t, deleted, "another pool in the namespace falls back to the per-secret path")
+	for _, action := range cpr.KubeClient.(*kubefake.Clientset).Actions() {
+		assert.NotEqual(t, "delete-collection", action.GetVerb(), "no DeleteCollection while other pools remain")
+	}
+}
+
+func TestReconcileClusterPoolDeleteMixedPullSecretRefs(t *testing.T) {
+
+	ctx := context.Background()
+
+	// The deleted pool has a pull secret, its sibling does not
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.PullSecretRef = nil
+	cpr.Client.Create(ctx, sibling, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01")
+
+	assert.NotPanics(t, func() {
+		_, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	})
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "pull secret not referenced by the sibling is deleted")
+
+	// The deleted pool has no pull secret, its sibling does
+	cpr = GetClusterPoolsReconciler()
+
+	cp = GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Spec.PullSecretRef = nil
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp"), &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01")
+
+	assert.NotPanics(t, func() {
+		_, _, err := deleteResources(ctx, cpr, cp)
+		assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	})
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "sibling's pull secret is kept")
+}
+
+func TestReconcileClusterPoolDeleteNonePlatform(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "none")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cpType, providerSecretName := getCPDetails(*cp)
+	assert.Equal(t, CP_TYPE_NONE, cpType, "pool without a cloud platform is detected")
+	assert.Empty(t, providerSecretName, "pool without a cloud platform has no provider secret")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "pull secret is deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "install-config secret is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "no provider secret cleanup is attempted")
+}
+
+func TestReconcileClusterPoolPaused(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{PAUSED: "True"}
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool is paused, the annotation is parsed like the retain annotation")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Empty(t, cp.Finalizers, "a paused cluster pool does not get the finalizer")
+
+	cp.Annotations[PAUSED] = "false"
+	cpr.Client.Update(ctx, cp)
+
+	_, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool is unpaused")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Equal(t, []string{FINALIZER}, cp.Finalizers, "an unpaused cluster pool gets the finalizer")
+}
+
+func TestAnnotationEnabled(t *testing.T) {
+
+	for _, value := range []string{"true", "True", "TRUE", "1"} {
+		assert.True(t, annotationEnabled(map[string]string{PAUSED: value}, PAUSED), value+" enables the annotation")
+	}
+	for _, value := range []string{"", "false", "False", "yes"} {
+		assert.False(t, annotationEnabled(map[string]string{PAUSED: value}, PAUSED), "\""+value+"\" does not enable the annotation")
+	}
+	assert.False(t, annotationEnabled(nil, PAUSED), "a missing annotation is not enabled")
+}
+
+func TestReconcileClusterPoolDeletePaused(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{PAUSED: "true"}
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the deleted cluster pool is paused")
+
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "a paused cluster pool keeps its secrets")
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Nil(t, err, "nil, when the paused cluster pool keeps its finalizer")
+
+	delete(cp.Annotations, PAUSED)
+	cpr.Client.Update(ctx, cp)
+
+	_, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the unpaused cluster pool was cleaned up")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "an unpaused cluster pool cleans up its secrets")
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.True(t, k8serrors.IsNotFound(err), "the unpaused cluster pool is removed with its finalizer")
+}
+
+func TestReconcileClusterPoolDeleteCleanupDisabled(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.DisableCleanup = true
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when cleanup is globally disabled")
+
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret is kept while cleanup is disabled: "+name)
+	}
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "the labeled namespace is kept while cleanup is disabled")
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.True(t, k8serrors.IsNotFound(err), "the finalizer is still removed, so the cluster pool is deleted")
+}
+
+func getClusterClaim(name string, poolName string) *hivev1.ClusterClaim {
+	return &hivev1.ClusterClaim{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: CP_NAMESPACE,
+		},
+		Spec: hivev1.ClusterClaimSpec{
+			ClusterPoolName: poolName,
+		},
+	}
+}
+
+func TestReconcileClusterPoolDeleteWaitsForClaims(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	claim := getClusterClaim("claim01", CP_NAME)
+	cpr.Client.Create(ctx, claim, &client.CreateOptions{})
+	cpr.Client.Create(ctx, getClusterClaim("claim02", CP_NAME+"02"), &client.CreateOptions{})
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool waits for its claims")
+	assert.Equal(t, CLAIMS_REQUEUE, result.RequeueAfter, "the pool is requeued while claims remain")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "secrets are kept while claims remain")
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Nil(t, err, "the pool keeps its finalizer while claims remain")
+
+	cpr.Client.Delete(ctx, claim)
+
+	result, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool was cleaned up")
+	assert.Zero(t, result.RequeueAfter, "no requeue once the claims are released")
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret is deleted once the claims are released: "+name)
+	}
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.True(t, k8serrors.IsNotFound(err), "the finalizer is removed once the claims are released")
+}
+
+func TestDeleteResourcesConcurrentSameNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	// Each pool has its own pull secret and shares the install-config and provider secrets
+	cp1 := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp1.Spec.PullSecretRef.Name = "pull01"
+	cp2 := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+	cp2.Spec.PullSecretRef.Name = "pull02"
+	for _, cp := range []*hivev1.ClusterPool{cp1, cp2} {
+		cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	}
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "pull01", "pull02", "secret02", "secret03")
+
+	var inFlight, maxInFlight atomic.Int32
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			current := inFlight.Add(1)
+			if current > maxInFlight.Load() {
+				maxInFlight.Store(current)
+			}
+			time.Sleep(10 * time.Millisecond)
+			inFlight.Add(-1)
+			return false, nil, nil
+		})
+
+	var wg sync.WaitGroup
+	for _, cp := range []*hivev1.ClusterPool{cp1, cp2} {
+		wg.Add(1)
+		go func(cp *hivev1.ClusterPool) {
+			defer wg.Done()
+			_, _, err := deleteResources(ctx, cpr, cp)
+			assert.Nil(t, err, "nil, when clusterPool delete was successful")
+		}(cp)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxInFlight.Load(), "the cleanup of pools in one namespace does not overlap")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "pull01"), "the unshared pull secret of the first pool is deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "pull02"), "the unshared pull secret of the second pool is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "the shared install-config secret is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the shared provider secret is kept")
+}
+
+func TestRemoveFinalizerStaleResourceVersion(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+
+	stale := cp.DeepCopy()
+
+	// Hive touches the pool after it was read
+	cp.Labels = map[string]string{"hive": "touched"}
+	cpr.Client.Update(ctx, cp)
+	assert.NotEqual(t, stale.ResourceVersion, cp.ResourceVersion, "the stored pool has moved on")
+
+	err := removeFinalizer(ctx, cpr, stale)
+	assert.Nil(t, err, "nil, when the finalizer is removed from a stale copy")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Empty(t, cp.Finalizers, "the finalizer was removed")
+	assert.Equal(t, "touched", cp.Labels["hive"], "the concurrent change is kept")
+}
+
+func TestRemoveFinalizerCleanupCompletedAt(t *testing.T) {
+
+	ctx := context.Background()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+
+	var patched []byte
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithObjects(cp.DeepCopy()).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patched, _ = patch.Data(obj)
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	}).Build()
+
+	err := removeFinalizer(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when the finalizer is removed")
+
+	completedAt, err := time.Parse(time.RFC3339, cp.Annotations[CLEANUP_COMPLETED_AT])
+	assert.Nil(t, err, "the completion time is RFC3339")
+	assert.WithinDuration(t, time.Now(), completedAt, time.Minute, "the completion time is the finalizer removal")
+
+	assert.Contains(t, string(patched), CLEANUP_COMPLETED_AT, "the annotation is set by the patch removing the finalizer")
+	assert.Contains(t, string(patched), `"finalizers":null`, "the finalizer is removed by the same patch")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Empty(t, cp.Finalizers, "the finalizer was removed")
+	assert.Equal(t, completedAt.Format(time.RFC3339), cp.Annotations[CLEANUP_COMPLETED_AT], "the stored pool has the annotation")
+}
+
+// sleepUntilDone stands in for a hung apiserver, returning only when the call's context expires
+func sleepUntilDone(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(10 * time.Second):
+		return nil
+	}
+}
+
+func getHungClusterPoolsReconciler(funcs interceptor.Funcs, objs ...client.Object) *ClusterPoolsReconciler {
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithInterceptorFuncs(funcs).Build()
+	cpr.ClientTimeout = 10 * time.Millisecond
+	return cpr
+}
+
+func TestReconcileClusterPoolClientTimeout(t *testing.T) {
+
+	ctx := context.Background()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cpr := getHungClusterPoolsReconciler(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return sleepUntilDone(ctx)
+		},
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return sleepUntilDone(ctx)
+		},
+	}, cp)
+
+	err := setFinalizer(ctx, cpr, cp.DeepCopy())
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "setFinalizer returns once the client timeout expires")
+
+	withFinalizer := cp.DeepCopy()
+	withFinalizer.Finalizers = []string{FINALIZER}
+	err = removeFinalizer(ctx, cpr, withFinalizer)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "removeFinalizer returns once the client timeout expires")
+
+	_, _, err = deleteResources(ctx, cpr, cp)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "deleteResources returns once the client timeout expires")
+}
+
+func TestWithClientTimeout(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+
+	ctx, cancel := withClientTimeout(context.Background(), cpr)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok, "the context has a deadline")
+	assert.WithinDuration(t, time.Now().Add(CLIENT_TIMEOUT), deadline, time.Second, "defaults to CLIENT_TIMEOUT")
+
+	cpr.ClientTimeout = time.Minute
+	ctx, cancel = withClientTimeout(context.Background(), cpr)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second, "uses ClientTimeout when set")
+}
+
+func TestApplyLeaderElection(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.LeaderElection = true
+
+	var options ctrl.Options
+	cpr.ApplyLeaderElection(&options)
+	assert.True(t, options.LeaderElection)
+	assert.Equal(t, LEADER_ELECTION_ID, options.LeaderElectionID, "defaults the lease name")
+
+	cpr.LeaderElectionID = "canary-clusterpools-controller"
+	cpr.ApplyLeaderElection(&options)
+	assert.Equal(t, "canary-clusterpools-controller", options.LeaderElectionID)
+}
+
+func TestReconcileClusterPoolDeleteCleanupScope(t *testing.T) {
+
+	tests := []struct {
+		scope            CleanupScope
+		deleted          []string
+		kept             []string
+		namespaceDeleted bool
+	}{
+		{scope: "", deleted: []string{"secret01", "secret02", "secret03", "secret04"}, namespaceDeleted: true},
+		{scope: CLEANUP_SCOPE_ALL, deleted: []string{"secret01", "secret02", "secret03", "secret04"}, namespaceDeleted: true},
+		{scope: CLEANUP_SCOPE_PROVIDER_ONLY, deleted: []string{"secret03", "secret04"}, kept: []string{"secret01", "secret02"}},
+		{scope: CLEANUP_SCOPE_NONE, kept: []string{"secret01", "secret02", "secret03", "secret04"}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.scope), func(t *testing.T) {
+
+			ctx := context.Background()
+
+			cpr := GetClusterPoolsReconciler()
+			cpr.CleanupScope = test.scope
+
+			cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+			createDeletingClusterPool(ctx, cpr, cp)
+			seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03", "secret04")
+			cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+			_, err := cpr.Reconcile(ctx, getRequest())
+			assert.Nil(t, err, "nil, when the cluster pool was cleaned up")
+
+			for _, name := range test.deleted {
+				assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret in the cleanup scope is deleted: "+name)
+			}
+			for _, name := range test.kept {
+				assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret outside the cleanup scope is kept: "+name)
+			}
+			_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+			assert.Equal(t, test.namespaceDeleted, k8serrors.IsNotFound(err), "the namespace is only deleted with the All scope")
+			err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+			assert.True(t, k8serrors.IsNotFound(err), "the finalizer is removed with every scope")
+		})
+	}
+}
+
+func TestReconcileClusterPoolDeleteProviderOnlySharedType(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.CleanupScope = CLEANUP_SCOPE_PROVIDER_ONLY
+
+	// The provider credentials double as the pull secret, which the ProviderOnly scope never deletes
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Spec.PullSecretRef.Name = "secret03"
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret02", "secret03")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "a provider secret that is also the pull secret is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "the install-config secret is kept")
+}
+
+func TestReconcileClusterPoolDeleteShutdown(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+
+	// The manager shuts down while the last pool secret is deleted
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.(clienttesting.DeleteAction).GetName() == "secret03" {
+				cancel()
+			}
+			return false, nil, nil
+		})
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the interrupted cleanup is requeued")
+	assert.True(t, result.Requeue, "the interrupted cleanup is requeued")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the step in progress completes")
+	_, err = cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, v1.GetOptions{})
+	assert.Nil(t, err, "the namespace is not deleted after the shutdown")
+
+	var found hivev1.ClusterPool
+	assert.Nil(t, cpr.Client.Get(context.Background(), getNamespaceName(CP_NAMESPACE, CP_NAME), &found), "the cluster pool is kept")
+	assert.Equal(t, []string{FINALIZER}, found.Finalizers, "the finalizer stays for a retry")
+	assert.Nil(t, getPoolCondition(&found, CONDITION_CLEANUP_FAILED), "an interrupted cleanup is not reported as failed")
+}
+
+func TestGetSecretDecisions(t *testing.T) {
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.PullSecretRef.Name = "secret11"
+	sibling.Spec.Platform.GCP.CredentialsSecretRef.Name = "secret13"
+
+	decisions := getSecretDecisions(cp, []hivev1.ClusterPool{*cp, *sibling},
+		[]string{"secret/secret01", "secret/secret03", "secret/" + CP_NAME + "-secret02", "secret/cd01/cd01-admin-kubeconfig"})
+
+	assert.Equal(t, map[string]secretDecision{
+		"secret01":            {Deleted: true},
+		"secret02":            {Shared: true},
+		"secret03":            {Deleted: true},
+		CP_NAME + "-secret02": {Deleted: true},
+	}, decisions, "a decision per referenced or deleted secret of the namespace")
+}
+
+func TestDeleteResourcesDecisionTrace(t *testing.T) {
+
+	ctx := context.Background()
+
+	var logged []string
+	cpr := GetClusterPoolsReconciler()
+	cpr.Log = funcr.New(func(prefix, args string) {
+		logged = append(logged, args)
+	}, funcr.Options{Verbosity: DEBUG})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.Platform.GCP.CredentialsSecretRef.Name = "secret13"
+	cpr.Client.Create(ctx, sibling, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	var trace string
+	for _, line := range logged {
+		if strings.Contains(line, "Secret cleanup decisions") {
+			trace = line
+		}
+	}
+	assert.Contains(t, trace, `"secret01"={"shared"=true "deleted"=false}`, "the shared pull secret is kept")
+	assert.Contains(t, trace, `"secret02"={"shared"=true "deleted"=false}`, "the shared install-config secret is kept")
+	assert.Contains(t, trace, `"secret03"={"shared"=false "deleted"=true}`, "the provider secret is deleted")
+}
+
+func TestReconcileClusterPoolDeleteRequireManagedLabel(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.RequireManagedLabel = true
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	labeled := getSecret(CP_NAMESPACE, "secret01")
+	labeled.Labels = map[string]string{LABEL_NAMESPACE: "other-tool"}
+	annotated := getSecret(CP_NAMESPACE, "secret02")
+	annotated.Annotations = map[string]string{MANAGED: "true"}
+	for _, secret := range []*corev1.Secret{labeled, annotated, getSecret(CP_NAMESPACE, "secret03")} {
+		cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+	}
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.ElementsMatch(t, []string{"secret/secret01", "secret/secret02"}, deleted, "only the managed secrets are deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "a secret with the managed-by label is deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret02"), "a secret with the managed annotation is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "a secret without the label or annotation is kept")
+}
+
+func TestReconcileClusterPoolDeleteManagedLabelNotRequired(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Len(t, deleted, 3, "unlabeled secrets are deleted unless RequireManagedLabel is set")
+}
+
+func TestReconcileClusterPoolDeleteProvisionLeftovers(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"-east", "aws")
+	sibling.Spec.PullSecretRef.Name = CP_NAME + "-shared-pull"
+	cpr.Client.Create(ctx, sibling, &client.CreateOptions{})
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03", CP_NAME+"-unlabeled")
+	for _, name := range []string{CP_NAME + "-install-x7k2p", CP_NAME + "-shared-pull", CP_NAME + "-east-install-q9z4m", "other-install-x7k2p"} {
+		secret := getSecret(CP_NAMESPACE, name)
+		secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+		cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, v1.CreateOptions{})
+	}
+
+	deleted, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.Contains(t, deleted, "secret/"+CP_NAME+"-install-x7k2p", "the failed-provision leftover is reported as deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, CP_NAME+"-install-x7k2p"), "the labeled, unreferenced secret with the pool prefix is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, CP_NAME+"-unlabeled"), "a secret without the managed-by label is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, CP_NAME+"-shared-pull"), "a secret another pool references is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, CP_NAME+"-east-install-q9z4m"), "a secret with the prefix of another pool is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "other-install-x7k2p"), "a secret without the pool prefix is kept")
+}
+
+func TestReconcileClusterPoolSameGeneration(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+	cp.Generation = 1
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	gets := 0
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			gets++
+			return false, nil, nil
+		})
+
+	for i := 0; i < 3; i++ {
+		_, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when the cluster pool is reconciled")
+	}
+	assert.Equal(t, 3, gets, "the secrets are only checked by the first reconcile at the generation")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	cp.Generation = 2
+	cpr.Client.Update(ctx, cp)
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool is reconciled")
+	assert.Equal(t, 6, gets, "the secrets are checked again at a new generation")
+
+	cpr.reconciledGenerations.Clear()
+	_, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool is reconciled")
+	assert.Equal(t, 9, gets, "the secrets are checked again once the generations are forgotten")
+}
+
+func TestReconcileClusterPoolMissingSecret(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02")
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the missing secret was reported")
+	assert.Equal(t, MISSING_SECRET_REQUEUE, result.RequeueAfter, "requeued until the secret exists")
+
+	var cp hivev1.ClusterPool
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	condition := getPoolCondition(&cp, CONDITION_MISSING_SECRET)
+	if assert.NotNil(t, condition, "the MissingSecret condition is set") {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status, "a secret is missing")
+		assert.Equal(t, "Missing secrets: secret03", condition.Message, "the missing provider secret is listed")
+	}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03")
+
+	result, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when all secrets exist")
+	assert.Zero(t, result.RequeueAfter, "no requeue once the secrets exist")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	condition = getPoolCondition(&cp, CONDITION_MISSING_SECRET)
+	if assert.NotNil(t, condition, "the MissingSecret condition is kept") {
+		assert.Equal(t, corev1.ConditionFalse, condition.Status, "the condition is cleared once the secret is created")
+	}
+}
+
+func TestReconcileClusterPoolNoMissingSecret(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cpr.Client.Create(ctx, GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool references no secrets")
+	assert.Zero(t, result.RequeueAfter, "no requeue without secret refs")
+
+	var cp hivev1.ClusterPool
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp)
+	assert.Nil(t, getPoolCondition(&cp, CONDITION_MISSING_SECRET), "no condition is set without missing secrets")
+}
+
+func TestReconcileClusterPoolDisableFinalizer(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.DisableFinalizer = true
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool was reconciled")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Empty(t, cp.Finalizers, "no finalizer is added")
+
+	// Without the finalizer the pool is gone at once, cleanup runs from the delete event
+	cpr.Client.Delete(ctx, cp)
+	assert.True(t, eventFilter(cpr).Delete(event.DeleteEvent{Object: cp}), "delete events are reconciled")
+
+	_, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when cleanup from the deleted pool's last state was successful")
+
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret is cleaned up from the delete event: "+name)
+	}
+}
+
+func TestReconcileClusterPoolDisableFinalizerExisting(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.DisableFinalizer = true
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool with the finalizer was cleaned up")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "a pool that has the finalizer is still cleaned up")
+	err = cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.True(t, k8serrors.IsNotFound(err), "the existing finalizer is still removed")
+}
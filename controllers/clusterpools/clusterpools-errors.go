@@ -0,0 +1,67 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"errors"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrSecretDeletionFailed is returned by deleteResources when a secret of the cluster pool could not be deleted
+type ErrSecretDeletionFailed struct {
+	ClusterPool types.NamespacedName
+	Err         error
+}
+
+func (e *ErrSecretDeletionFailed) Error() string {
+	return fmt.Sprintf("failed to delete the secrets of cluster pool %s: %v", e.ClusterPool, e.Err)
+}
+
+func (e *ErrSecretDeletionFailed) Unwrap() error { return e.Err }
+
+// ErrSecretReadFailed is returned by the SecretCleaner when reading a referenced secret before deleting it
+// failed for a reason other than the secret being gone
+type ErrSecretReadFailed struct {
+	Secret types.NamespacedName
+	Err    error
+}
+
+func (e *ErrSecretReadFailed) Error() string {
+	return fmt.Sprintf("failed to read secret %s: %v", e.Secret, e.Err)
+}
+
+func (e *ErrSecretReadFailed) Unwrap() error { return e.Err }
+
+// isSecretReadInternalError reports whether reading a referenced secret failed with an internal server error
+func isSecretReadInternalError(err error) bool {
+	var readErr *ErrSecretReadFailed
+	return errors.As(err, &readErr) && k8serrors.IsInternalError(readErr.Err)
+}
+
+// ErrNamespaceDeletionFailed is returned by deleteResources when the namespace of the last cluster pool could
+// not be deleted
+type ErrNamespaceDeletionFailed struct {
+	Namespace string
+	Err       error
+}
+
+func (e *ErrNamespaceDeletionFailed) Error() string {
+	return fmt.Sprintf("failed to delete namespace %s: %v", e.Namespace, e.Err)
+}
+
+func (e *ErrNamespaceDeletionFailed) Unwrap() error { return e.Err }
+
+// ErrFinalizerUpdateFailed is returned by removeFinalizer when the cluster pool could not be patched
+type ErrFinalizerUpdateFailed struct {
+	ClusterPool types.NamespacedName
+	Err         error
+}
+
+func (e *ErrFinalizerUpdateFailed) Error() string {
+	return fmt.Sprintf("failed to remove the finalizer of cluster pool %s: %v", e.ClusterPool, e.Err)
+}
+
+func (e *ErrFinalizerUpdateFailed) Unwrap() error { return e.Err }
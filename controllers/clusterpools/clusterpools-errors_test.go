@@ -0,0 +1,122 @@
+package clusterpools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestDeleteResourcesSecretDeletionFailed(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "secret01", errors.New("conflict"))
+	})
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	var secretErr *ErrSecretDeletionFailed
+	if assert.True(t, errors.As(err, &secretErr), "a failed secret delete is an ErrSecretDeletionFailed") {
+		assert.Equal(t, getNamespaceName(CP_NAMESPACE, CP_NAME), secretErr.ClusterPool, "the error names the cluster pool")
+	}
+	assert.True(t, k8serrors.IsConflict(err), "the cause is still detectable")
+	assert.True(t, isRetryable(err), "a wrapped conflict is retried")
+}
+
+func TestDeleteResourcesNamespaceDeletionFailed(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "namespaces", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, CP_NAMESPACE, errors.New("denied"))
+	})
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME, "aws"))
+
+	var namespaceErr *ErrNamespaceDeletionFailed
+	if assert.True(t, errors.As(err, &namespaceErr), "a failed namespace delete is an ErrNamespaceDeletionFailed") {
+		assert.Equal(t, CP_NAMESPACE, namespaceErr.Namespace, "the error names the namespace")
+	}
+	assert.True(t, k8serrors.IsForbidden(err), "the cause is still detectable")
+
+	var secretErr *ErrSecretDeletionFailed
+	assert.False(t, errors.As(err, &secretErr), "a namespace failure is not a secret failure")
+}
+
+func TestRemoveFinalizerUpdateFailed(t *testing.T) {
+
+	ctx := context.Background()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Finalizers = []string{FINALIZER}
+
+	cpr := getHungClusterPoolsReconciler(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return k8serrors.NewServiceUnavailable("unavailable")
+		},
+	}, cp.DeepCopy())
+
+	err := removeFinalizer(ctx, cpr, cp)
+
+	var finalizerErr *ErrFinalizerUpdateFailed
+	if assert.True(t, errors.As(err, &finalizerErr), "a failed finalizer patch is an ErrFinalizerUpdateFailed") {
+		assert.Equal(t, getNamespaceName(CP_NAMESPACE, CP_NAME), finalizerErr.ClusterPool, "the error names the cluster pool")
+	}
+	assert.True(t, k8serrors.IsServiceUnavailable(err), "the cause is still detectable")
+}
+
+func TestReconcileClusterPoolDeleteSecretReadInternalError(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.(clienttesting.GetAction).GetName() == "secret01" {
+			return true, nil, k8serrors.NewInternalError(errors.New("etcd leader changed"))
+		}
+		return false, nil, nil
+	})
+
+	createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	var readErr *ErrSecretReadFailed
+	if assert.True(t, errors.As(err, &readErr), "a failed pull secret read is an ErrSecretReadFailed") {
+		assert.Equal(t, "secret01", readErr.Secret.Name, "the error names the pull secret")
+	}
+	assert.True(t, k8serrors.IsInternalError(err), "the cause is preserved")
+
+	result, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the transient read failure is requeued")
+	assert.Greater(t, result.RequeueAfter, time.Duration(0), "the pool is requeued after a delay")
+
+	var cp hivev1.ClusterPool
+	assert.Nil(t, cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), &cp), "the pool keeps its finalizer until the read succeeds")
+}
+
+func TestIsRetryableInternalError(t *testing.T) {
+
+	internal := k8serrors.NewInternalError(errors.New("boom"))
+
+	assert.True(t, isRetryable(&ErrSecretReadFailed{Err: internal}), "an internal error reading a secret is retried")
+	assert.False(t, isRetryable(internal), "other internal errors are returned as is")
+	assert.False(t, isRetryable(&ErrSecretReadFailed{Err: errors.New("unexpected")}), "unknown secret read errors are returned as is")
+}
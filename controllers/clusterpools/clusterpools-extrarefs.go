@@ -0,0 +1,43 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"strings"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// getExtraSecretRefs returns the secrets the cluster pool references at the field paths, dot separated paths into
+// the cluster pool object like "metadata.annotations.release-image-secret". Paths that are unset, or do not hold
+// a string, are skipped.
+func getExtraSecretRefs(paths []string, cp hivev1.ClusterPool) []secretRef {
+	if len(paths) == 0 {
+		return nil
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&cp)
+	if err != nil {
+		return nil
+	}
+
+	var refs []secretRef
+	for _, path := range paths {
+		name, found, err := unstructured.NestedString(obj, strings.Split(path, ".")...)
+		if err != nil || !found || name == "" {
+			continue
+		}
+		refs = append(refs, secretRef{SECRET_TYPE_EXTRA, name})
+	}
+	return refs
+}
+
+// getExtraSecretRefNames returns the names of the secrets the cluster pool references at the field paths
+func getExtraSecretRefNames(paths []string, cp hivev1.ClusterPool) []string {
+	var names []string
+	for _, ref := range getExtraSecretRefs(paths, cp) {
+		names = append(names, ref.name)
+	}
+	return names
+}
@@ -0,0 +1,136 @@
+package clusterpools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const RELEASE_IMAGE_SECRET_PATH = "metadata.annotations.release-image-secret"
+
+func TestGetExtraSecretRefs(t *testing.T) {
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{"release-image-secret": "secret05"}
+	cp.Spec.ImageSetRef.Name = "img4.16"
+
+	refs := getExtraSecretRefs([]string{RELEASE_IMAGE_SECRET_PATH, "spec.imageSetRef.name", "spec.size", "spec.unset.name"}, *cp)
+	assert.Equal(t, []secretRef{{SECRET_TYPE_EXTRA, "secret05"}, {SECRET_TYPE_EXTRA, "img4.16"}}, refs,
+		"string fields at the paths are refs, fields that are unset or not strings are skipped")
+
+	assert.Empty(t, getExtraSecretRefs(nil, *cp), "no refs without paths")
+}
+
+func TestDeleteResourcesExtraSecretRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ExtraSecretRefPaths = []string{RELEASE_IMAGE_SECRET_PATH}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Annotations = map[string]string{"release-image-secret": "secret05"}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03", "secret05")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret05"), "unshared extra secret should be deleted")
+}
+
+func TestDeleteResourcesSharedExtraSecretRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ExtraSecretRefPaths = []string{RELEASE_IMAGE_SECRET_PATH}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Annotations = map[string]string{"release-image-secret": "secret05"}
+
+	cp2 := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+	cp2.Spec.Platform.AWS.CredentialsSecretRef.Name = "secret13"
+	cp2.Annotations = map[string]string{"release-image-secret": "secret05"}
+	cpr.Client.Create(ctx, cp2, &client.CreateOptions{})
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03", "secret05")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "unshared provider secret should be deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret05"), "extra secret shared with another pool should be kept")
+}
+
+func TestDeleteResourcesRetainedExtraSecretRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ExtraSecretRefPaths = []string{RELEASE_IMAGE_SECRET_PATH}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	cp.Annotations = map[string]string{"release-image-secret": "secret05", RETAIN_SECRETS: SECRET_TYPE_EXTRA}
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret05")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret05"), "retained extra secret should be kept")
+}
+
+func TestSweepOrphanedSecretsExtraSecretRef(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.EnableOrphanSweep = true
+	cpr.ExtraSecretRefPaths = []string{RELEASE_IMAGE_SECRET_PATH}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{"release-image-secret": "secret05"}
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), v1.CreateOptions{})
+	cpr.Create(ctx, cp)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret05", time.Hour)
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were swept")
+	assert.Empty(t, deleted, "nothing is swept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret05"), "secret referenced at an extra field path is kept")
+}
+
+func TestRefsChangedExtraSecretRef(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ExtraSecretRefPaths = []string{RELEASE_IMAGE_SECRET_PATH}
+
+	oldCp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	newCp := oldCp.DeepCopy()
+	newCp.Annotations = map[string]string{"release-image-secret": "secret05"}
+
+	assert.True(t, refsChanged(cpr, oldCp, newCp), "a new secret at an extra field path changes the refs")
+}
+
+func TestWouldDeleteExtraSecretRef(t *testing.T) {
+
+	paths := []string{RELEASE_IMAGE_SECRET_PATH}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{"release-image-secret": "secret05"}
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+
+	assert.True(t, wouldDelete(nil, paths, cp, []hivev1.ClusterPool{*sibling}), "the extra secret no sibling references would be deleted")
+
+	sibling.Annotations = map[string]string{"release-image-secret": "secret05"}
+	assert.False(t, wouldDelete(nil, paths, cp, []hivev1.ClusterPool{*sibling}), "a sibling references every secret")
+}
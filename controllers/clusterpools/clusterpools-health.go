@@ -0,0 +1,25 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"fmt"
+	"net/http"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// HiveCRDReadyCheck reports not ready until the ClusterPool kind resolves through the client's REST mapper,
+// so the controller does not reconcile before the Hive CRDs are installed
+func HiveCRDReadyCheck(c client.Client) healthz.Checker {
+	gvk := hivev1.SchemeGroupVersion.WithKind("ClusterPool")
+
+	return func(_ *http.Request) error {
+		if _, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			return fmt.Errorf("hive %s CRD is not installed: %w", gvk.Kind, err)
+		}
+		return nil
+	}
+}
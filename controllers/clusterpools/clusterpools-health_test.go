@@ -0,0 +1,44 @@
+package clusterpools
+
+import (
+	"testing"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// getDiscoveryClient returns a client whose REST mapper knows the scheme's kinds, as discovery would
+// with the matching CRDs installed
+func getDiscoveryClient(scheme *runtime.Scheme) client.Client {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for gvk := range scheme.AllKnownTypes() {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	return clientfake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).Build()
+}
+
+func TestHiveCRDReadyCheck(t *testing.T) {
+
+	withHive := runtime.NewScheme()
+	clientgoscheme.AddToScheme(withHive)
+	hivev1.AddToScheme(withHive)
+
+	check := HiveCRDReadyCheck(getDiscoveryClient(withHive))
+	assert.Nil(t, check(nil), "nil, when the ClusterPool kind resolves")
+}
+
+func TestHiveCRDReadyCheckMissing(t *testing.T) {
+
+	withoutHive := runtime.NewScheme()
+	clientgoscheme.AddToScheme(withoutHive)
+
+	check := HiveCRDReadyCheck(getDiscoveryClient(withoutHive))
+	err := check(nil)
+	assert.NotNil(t, err, "not nil, when the ClusterPool kind does not resolve")
+	assert.Contains(t, err.Error(), "ClusterPool CRD is not installed", "the error names the missing CRD")
+}
@@ -0,0 +1,33 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// logLevels maps the log level names to the verbosity the controller logs at
+var logLevels = map[string]int{
+	"debug": DEBUG,
+	"info":  INFO,
+	"warn":  WARN,
+	"error": ERROR,
+}
+
+// ParseLogLevel returns the verbosity of a log level name, debug, info, warn or error, and INFO when unknown
+func ParseLogLevel(name string) int {
+	if level, found := logLevels[strings.ToLower(strings.TrimSpace(name))]; found {
+		return level
+	}
+	return INFO
+}
+
+// NewLogger returns a zap logger that prints the messages up to the verbosity. logr logs negative verbosities
+// at INFO, so the WARN and ERROR messages are only kept when INFO messages are printed too.
+func NewLogger(verbosity int) logr.Logger {
+	return zap.New(zap.Level(zapcore.Level(-max(verbosity, INFO))))
+}
@@ -0,0 +1,35 @@
+package clusterpools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogLevel(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		level int
+	}{
+		{"debug", DEBUG},
+		{"info", INFO},
+		{"warn", WARN},
+		{"error", ERROR},
+		{" DEBUG ", DEBUG},
+		{"", INFO},
+		{"verbose", INFO},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.level, ParseLogLevel(tt.name), "verbosity of log level "+tt.name)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+
+	assert.True(t, NewLogger(DEBUG).V(DEBUG).Enabled(), "debug messages are printed at DEBUG")
+	assert.False(t, NewLogger(INFO).V(DEBUG).Enabled(), "debug messages are not printed at INFO")
+	assert.True(t, NewLogger(INFO).V(WARN).Enabled(), "warnings are printed at INFO")
+	assert.True(t, NewLogger(ERROR).V(WARN).Enabled(), "warnings are never dropped")
+}
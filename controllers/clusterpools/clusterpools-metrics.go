@@ -0,0 +1,56 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	secretsDeletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "clusterpools_secrets_deleted_total",
+		Help: "Number of secrets deleted during cluster pool cleanup, by secret type",
+	}, []string{"type"})
+
+	namespacesDeletedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "clusterpools_namespaces_deleted_total",
+		Help: "Number of namespaces deleted after their last cluster pool was removed",
+	})
+
+	reconcileErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "clusterpools_reconcile_errors_total",
+		Help: "Number of cluster pool reconciles that returned an error",
+	})
+
+	finalizersAddedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "clusterpools_finalizer_added_total",
+		Help: "Number of cluster pools the cleanup finalizer was added to",
+	})
+
+	finalizersRemovedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "clusterpools_finalizer_removed_total",
+		Help: "Number of deleted cluster pools the cleanup finalizer was removed from",
+	})
+
+	orphanedSecrets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "clusterpools_orphaned_secrets",
+		Help: "Number of labeled secrets in managed namespaces that no cluster pool references, at the last count",
+	})
+
+	cleanupPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "clusterpools_cleanup_pending",
+		Help: "1 while the cleanup of a terminating cluster pool in the namespace is pending, 0 once it completed",
+	}, []string{"namespace"})
+
+	reconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "clusterpools_reconcile_duration_seconds",
+		Help:    "Time taken to reconcile a cluster pool, including cleanup",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(secretsDeletedTotal, namespacesDeletedTotal, reconcileErrorsTotal, finalizersAddedTotal, finalizersRemovedTotal,
+		orphanedSecrets, cleanupPending, reconcileDuration)
+}
@@ -0,0 +1,155 @@
+package clusterpools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMetricsSecretsDeleted(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+	createDeletingClusterPool(ctx, cpr, cp)
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret01"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret02"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret03"), v1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret04"), v1.CreateOptions{})
+
+	pull := testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_PULL))
+	installConfig := testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_INSTALLCONFIG))
+	provider := testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_PROVIDER))
+	certificates := testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_CERTIFICATES))
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when clusterPool delete reconcile successful")
+
+	assert.Equal(t, pull+1, testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_PULL)))
+	assert.Equal(t, installConfig+1, testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_INSTALLCONFIG)))
+	assert.Equal(t, provider+1, testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_PROVIDER)))
+	assert.Equal(t, certificates+1, testutil.ToFloat64(secretsDeletedTotal.WithLabelValues(SECRET_TYPE_CERTIFICATES)))
+}
+
+func TestMetricsReconcileErrors(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("apiserver unavailable")
+		})
+
+	createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	before := testutil.ToFloat64(reconcileErrorsTotal)
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.NotNil(t, err, "not nil, when the secret lookup fails")
+
+	assert.Equal(t, before+1, testutil.ToFloat64(reconcileErrorsTotal))
+}
+
+func TestMetricsReconcileDuration(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	var before dto.Metric
+	reconcileDuration.Write(&before)
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when clusterPool is not found")
+
+	var after dto.Metric
+	reconcileDuration.Write(&after)
+
+	assert.Equal(t, before.GetHistogram().GetSampleCount()+1, after.GetHistogram().GetSampleCount(), "the reconcile was observed")
+}
+
+func TestMetricsFinalizers(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	added := testutil.ToFloat64(finalizersAddedTotal)
+	removed := testutil.ToFloat64(finalizersRemovedTotal)
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the finalizer is added")
+	assert.Equal(t, added+1, testutil.ToFloat64(finalizersAddedTotal), "the added finalizer is counted")
+	assert.Equal(t, removed, testutil.ToFloat64(finalizersRemovedTotal), "no finalizer was removed")
+
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	cpr.Client.Delete(ctx, cp)
+
+	_, err = cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when clusterPool delete reconcile successful")
+	assert.Equal(t, added+1, testutil.ToFloat64(finalizersAddedTotal), "no finalizer was added")
+	assert.Equal(t, removed+1, testutil.ToFloat64(finalizersRemovedTotal), "the removed finalizer is counted")
+}
+
+func TestMetricsCleanupPending(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	// Read the gauge while the cleanup is still deleting the secrets
+	var during []float64
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			during = append(during, testutil.ToFloat64(cleanupPending.WithLabelValues(CP_NAMESPACE)))
+			return false, nil, nil
+		})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when clusterPool delete reconcile successful")
+
+	assert.NotEmpty(t, during, "secrets were deleted")
+	for _, value := range during {
+		assert.Equal(t, float64(1), value, "the cleanup is pending while it runs")
+	}
+	assert.Equal(t, float64(0), testutil.ToFloat64(cleanupPending.WithLabelValues(CP_NAMESPACE)), "the cleanup is complete")
+}
+
+func TestMetricsCleanupPendingFailed(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("apiserver unavailable")
+		})
+
+	createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.NotNil(t, err, "not nil, when the secret lookup fails")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(cleanupPending.WithLabelValues(CP_NAMESPACE)), "a failed cleanup stays pending")
+}
@@ -0,0 +1,153 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// namespaceReconciler cleans up the cluster pools of a deleted namespace that carries the finalizer, with
+// ManageNamespaceFinalizer, and then releases the namespace. Tooling that deletes the namespace before its
+// cluster pools no longer strands their cleanup.
+type namespaceReconciler struct {
+	r *ClusterPoolsReconciler
+}
+
+func (n *namespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r := n.r
+	log := r.Log.WithValues("Namespace", req.Name)
+
+	ns, err := r.KubeClient.CoreV1().Namespaces().Get(ctx, req.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if ns.DeletionTimestamp == nil || !controllerutil.ContainsFinalizer(ns, getFinalizerName(r)) {
+		return ctrl.Result{}, nil
+	}
+
+	log.V(INFO).Info("Namespace deleted, cleaning up its cluster pools")
+
+	var cps hivev1.ClusterPoolList
+	if err := r.List(ctx, &cps, client.InNamespace(ns.Name)); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range cps.Items {
+		cp := &cps.Items[i]
+		if annotationEnabled(cp.Annotations, PAUSED) || !watchesPool(r, cp) {
+			continue
+		}
+
+		deleted, requeueAfter, err := deleteResources(ctx, r, cp)
+		logDeleted(log, deleted)
+		if auditErr := recordAudit(ctx, r, cp, deleted); auditErr != nil {
+			log.V(WARN).Info("Failed to record the deleted resources in the audit config map", "configMap", r.AuditConfigMap.String(),
+				"resources", deleted, "error", auditErr.Error())
+		}
+		if err != nil || requeueAfter > 0 {
+			return ctrl.Result{RequeueAfter: requeueAfter}, err
+		}
+	}
+
+	return ctrl.Result{}, removeNamespaceFinalizer(ctx, r, ns)
+}
+
+// namespaceFinalizerFilter only passes the namespaces carrying the finalizer to the namespaceReconciler
+func namespaceFinalizerFilter(r *ClusterPoolsReconciler) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return controllerutil.ContainsFinalizer(obj, getFinalizerName(r))
+	})
+}
+
+// namespaceCleanupFilter only passes namespace updates changing the namespace label or the RETAIN_NAMESPACE
+// annotation, which decide whether the cluster pools of the namespace need the finalizer
+func namespaceCleanupFilter(r *ClusterPoolsReconciler) predicate.Funcs {
+	labelKey, _ := getNamespaceLabel(r)
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.GetLabels()[labelKey] != e.ObjectNew.GetLabels()[labelKey] ||
+				e.ObjectOld.GetAnnotations()[RETAIN_NAMESPACE] != e.ObjectNew.GetAnnotations()[RETAIN_NAMESPACE]
+		},
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+	}
+}
+
+// mapNamespaceToPools returns the requests of the watched cluster pools of the namespace, so a pool that did not
+// need the finalizer gets it once its namespace is labeled, or no longer retained
+func mapNamespaceToPools(ctx context.Context, r *ClusterPoolsReconciler, ns client.Object) []ctrl.Request {
+	var cps hivev1.ClusterPoolList
+	if err := r.List(ctx, &cps, client.InNamespace(ns.GetName())); err != nil {
+		r.Log.V(WARN).Info("Failed to list the cluster pools of an updated namespace", "namespace", ns.GetName(), "error", err.Error())
+		return nil
+	}
+
+	var requests []ctrl.Request
+	for i := range cps.Items {
+		cp := &cps.Items[i]
+		if !watchesPool(r, cp) {
+			continue
+		}
+		key := client.ObjectKeyFromObject(cp)
+		r.reconciledGenerations.Delete(key)
+		requests = append(requests, ctrl.Request{NamespacedName: key})
+		r.Log.V(DEBUG).Info("Namespace updated, reconciling its cluster pool", "namespace", cp.Namespace, "clusterPool", cp.Name)
+	}
+	return requests
+}
+
+// setNamespaceFinalizer adds the finalizer to the namespace of the cluster pool, when it carries the namespace label
+func setNamespaceFinalizer(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool) error {
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
+
+	ns, err := r.KubeClient.CoreV1().Namespaces().Get(ctx, cp.Namespace, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	labelKey, labelValue := getNamespaceLabel(r)
+	if ns.DeletionTimestamp != nil || ns.Labels[labelKey] != labelValue || !controllerutil.AddFinalizer(ns, getFinalizerName(r)) {
+		return nil
+	}
+	if _, err := r.KubeClient.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	r.Log.V(INFO).Info("Added finalizer to namespace", "namespace", ns.Name, "finalizer", getFinalizerName(r))
+
+	return nil
+}
+
+func removeNamespaceFinalizer(ctx context.Context, r *ClusterPoolsReconciler, ns *corev1.Namespace) error {
+	ctx, cancel := withClientTimeout(ctx, r)
+	defer cancel()
+
+	if !controllerutil.RemoveFinalizer(ns, getFinalizerName(r)) {
+		return nil
+	}
+	if _, err := r.KubeClient.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	r.Log.V(INFO).Info("Removed finalizer from namespace", "namespace", ns.Name, "finalizer", getFinalizerName(r))
+
+	return nil
+}
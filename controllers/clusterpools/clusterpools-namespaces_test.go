@@ -0,0 +1,181 @@
+package clusterpools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func getNamespaceRequest() ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{Name: CP_NAMESPACE}}
+}
+
+// createDeletingNamespace creates the labeled namespace as deleted, held by the finalizer
+func createDeletingNamespace(ctx context.Context, cpr *ClusterPoolsReconciler) {
+	ns := getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS})
+	ns.Finalizers = []string{FINALIZER}
+	ns.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+}
+
+func getNamespaceFinalizers(ctx context.Context, cpr *ClusterPoolsReconciler) []string {
+	ns, _ := cpr.KubeClient.CoreV1().Namespaces().Get(ctx, CP_NAMESPACE, metav1.GetOptions{})
+	return ns.Finalizers
+}
+
+func TestReconcileClusterPoolNamespaceFinalizer(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, labels := range []map[string]string{{LABEL_NAMESPACE: CLUSTERPOOLS}, nil} {
+		cpr := GetClusterPoolsReconciler()
+		cpr.ManageNamespaceFinalizer = true
+
+		cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, labels), metav1.CreateOptions{})
+		cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+		_, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when the cluster pool was reconciled")
+
+		if labels != nil {
+			assert.Equal(t, []string{FINALIZER}, getNamespaceFinalizers(ctx, cpr), "the labeled namespace gets the finalizer")
+		} else {
+			assert.Empty(t, getNamespaceFinalizers(ctx, cpr), "an unlabeled namespace is left alone")
+		}
+	}
+}
+
+func TestReconcileClusterPoolNamespaceFinalizerDisabled(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the cluster pool was reconciled")
+	assert.Empty(t, getNamespaceFinalizers(ctx, cpr), "no namespace finalizer without ManageNamespaceFinalizer")
+}
+
+func TestReconcileNamespaceDelete(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ManageNamespaceFinalizer = true
+
+	createDeletingNamespace(ctx, cpr)
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := (&namespaceReconciler{r: cpr}).Reconcile(ctx, getNamespaceRequest())
+	assert.Nil(t, err, "nil, when the namespace was cleaned up")
+
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret "+name+" of the cluster pool is deleted")
+	}
+	assert.Empty(t, getNamespaceFinalizers(ctx, cpr), "the finalizer is removed from the namespace")
+}
+
+func TestReconcileNamespaceDeleteWaitsForClaims(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ManageNamespaceFinalizer = true
+
+	createDeletingNamespace(ctx, cpr)
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	cpr.Client.Create(ctx, getClusterClaim("claim01", CP_NAME))
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01")
+
+	result, err := (&namespaceReconciler{r: cpr}).Reconcile(ctx, getNamespaceRequest())
+	assert.Nil(t, err, "nil, when the cleanup waits for the claims")
+	assert.Equal(t, CLAIMS_REQUEUE, result.RequeueAfter, "the namespace is checked again")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "secrets are kept while claims remain")
+	assert.Equal(t, []string{FINALIZER}, getNamespaceFinalizers(ctx, cpr), "the namespace is held while claims remain")
+}
+
+func TestReconcileNamespaceNotDeleted(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.ManageNamespaceFinalizer = true
+
+	ns := getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS})
+	controllerutil.AddFinalizer(ns, FINALIZER)
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01")
+
+	_, err := (&namespaceReconciler{r: cpr}).Reconcile(ctx, getNamespaceRequest())
+	assert.Nil(t, err, "nil, when the namespace is not deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "nothing is cleaned up before the namespace is deleted")
+	assert.Equal(t, []string{FINALIZER}, getNamespaceFinalizers(ctx, cpr), "the finalizer stays")
+}
+
+func TestNamespaceFinalizerFilter(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	filter := namespaceFinalizerFilter(cpr)
+
+	ns := getNamespace(CP_NAMESPACE, nil)
+	assert.False(t, filter.Generic(event.GenericEvent{Object: ns}), "namespaces without the finalizer are filtered out")
+
+	ns.Finalizers = []string{FINALIZER}
+	assert.True(t, filter.Generic(event.GenericEvent{Object: ns}), "namespaces with the finalizer pass")
+}
+
+func TestNamespaceCleanupFilter(t *testing.T) {
+
+	filter := namespaceCleanupFilter(GetClusterPoolsReconciler())
+	unlabeled := getNamespace(CP_NAMESPACE, nil)
+	labeled := getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS})
+	retained := labeled.DeepCopy()
+	retained.Annotations = map[string]string{RETAIN_NAMESPACE: "true"}
+	touched := labeled.DeepCopy()
+	touched.Labels["team"] = "a"
+
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: unlabeled, ObjectNew: labeled}), "labeling the namespace is passed")
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: retained, ObjectNew: labeled}), "removing the retain annotation is passed")
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: labeled, ObjectNew: touched}), "other label changes are dropped")
+	assert.False(t, filter.Create(event.CreateEvent{Object: labeled}), "namespace creations are dropped")
+}
+
+func TestReconcileClusterPoolNamespaceLabeledLater(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	ns := getNamespace(CP_NAMESPACE, nil)
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{RETAIN_SECRETS: "pull,installconfig,provider"}
+	cpr.Client.Create(ctx, cp)
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when the pool was reconciled")
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Empty(t, cp.Finalizers, "a pool retaining its secrets in an unlabeled namespace has no finalizer")
+
+	ns.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+	cpr.KubeClient.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+
+	requests := mapNamespaceToPools(ctx, cpr, ns)
+	assert.Equal(t, []ctrl.Request{getRequest()}, requests, "the pools of the labeled namespace are reconciled")
+
+	_, err = cpr.Reconcile(ctx, requests[0])
+	assert.Nil(t, err, "nil, when the pool was reconciled")
+	cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, CP_NAME), cp)
+	assert.Equal(t, []string{FINALIZER}, cp.Finalizers, "the pool gets the finalizer once its namespace is labeled")
+}
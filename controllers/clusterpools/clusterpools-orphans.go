@@ -0,0 +1,257 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// unreferencedSecrets returns the secrets none of the cluster pools reference, at their refs or the extra field
+// paths and as named by the resolver, or derived from their install-config
+func unreferencedSecrets(resolver SecretNameResolver, paths []string, pools []hivev1.ClusterPool, secrets []corev1.Secret) []corev1.Secret {
+	var refNames []string
+	for _, cp := range pools {
+		refNames = append(refNames, getReferencedSecretNames(resolver, paths, cp)...)
+	}
+
+	var unreferenced []corev1.Secret
+	for _, secret := range secrets {
+		if !slices.Contains(refNames, secret.Name) && !slices.ContainsFunc(pools, func(cp hivev1.ClusterPool) bool {
+			return isDerivedInstallConfig(cp, secret.Name)
+		}) {
+			unreferenced = append(unreferenced, secret)
+		}
+	}
+	return unreferenced
+}
+
+// orphanSweeper deletes orphaned secrets every OrphanSweepInterval, once the manager is elected leader
+type orphanSweeper struct {
+	r *ClusterPoolsReconciler
+}
+
+func (o *orphanSweeper) Start(ctx context.Context) error {
+	interval := o.r.OrphanSweepInterval
+	if interval <= 0 {
+		interval = ORPHAN_SWEEP_INTERVAL
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := sweepOrphanedSecrets(ctx, o.r); err != nil {
+				o.r.Log.V(WARN).Info("Orphaned secret sweep failed", "error", err.Error())
+			}
+		}
+	}
+}
+
+func (o *orphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// sweepOrphanedSecrets deletes the secrets carrying the managed-by label, in namespaces labeled for cleanup,
+// that no cluster pool references, to self-heal cleanups the controller missed, like a crash after the
+// finalizer was removed. Secrets younger than the sweep interval are kept, so a secret created for a cluster
+// pool that is not in the cache yet is not mistaken for an orphan. It returns the deleted "<namespace>/<name>".
+func sweepOrphanedSecrets(ctx context.Context, r *ClusterPoolsReconciler) ([]string, error) {
+	if r.DisableCleanup {
+		r.Log.V(WARN).Info("Cleanup is globally disabled, skipping the orphaned secret sweep", "env", DISABLE_CLEANUP_ENV)
+		return nil, nil
+	}
+	if scope := getCleanupScope(r); scope != CLEANUP_SCOPE_ALL {
+		r.Log.V(INFO).Info("Skipping the orphaned secret sweep", "cleanupScope", scope)
+		return nil, nil
+	}
+
+	minAge := r.OrphanSweepInterval
+	if minAge <= 0 {
+		minAge = ORPHAN_SWEEP_INTERVAL
+	}
+
+	orphaned, err := listOrphanedSecrets(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, secret := range orphaned {
+		if time.Since(secret.CreationTimestamp.Time) < minAge {
+			continue
+		}
+		if err := deleteWithRetries(r.DeleteRetries, func() error {
+			return r.KubeClient.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		}); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		r.Log.V(INFO).Info("Deleted orphaned secret", "name", secret.Name, "namespace", secret.Namespace)
+		secretsDeletedTotal.WithLabelValues(SECRET_TYPE_LABELED).Inc()
+		deleted = append(deleted, secret.Namespace+"/"+secret.Name)
+	}
+
+	return deleted, nil
+}
+
+// OrphanedSecretOptions configures FindOrphanedSecrets like the ClusterPoolsReconciler fields of the same names.
+// The zero value finds the secrets orphaned under the default namespace label, in all namespaces.
+type OrphanedSecretOptions struct {
+	NamespaceLabel      string
+	NamespaceLabelValue string
+
+	// Namespace limits the search to a single namespace
+	Namespace string
+
+	SecretNameResolver  SecretNameResolver
+	ExtraSecretRefPaths []string
+
+	// CrossNamespaceRefCounting keeps the secrets a cluster pool of any namespace references by name
+	CrossNamespaceRefCounting bool
+}
+
+// FindOrphanedSecrets returns the secrets carrying the managed-by label, in the namespaces labeled for cleanup,
+// that no cluster pool references. Nothing is deleted, so operators can audit what the controller considers
+// unowned before trusting it with their namespaces.
+func FindOrphanedSecrets(ctx context.Context, c client.Client, opts OrphanedSecretOptions) ([]types.NamespacedName, error) {
+	labelKey, labelValue := opts.NamespaceLabel, opts.NamespaceLabelValue
+	if labelKey == "" {
+		labelKey = LABEL_NAMESPACE
+	}
+	if labelValue == "" {
+		labelValue = CLUSTERPOOLS
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces, client.MatchingLabels{labelKey: labelValue}); err != nil {
+		return nil, err
+	}
+
+	orphaned, err := findOrphanedSecrets(ctx, c, opts, namespaces.Items, func(namespace string) ([]corev1.Secret, error) {
+		var secrets corev1.SecretList
+		err := c.List(ctx, &secrets, client.InNamespace(namespace), client.HasLabels{labelKey})
+		return secrets.Items, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []types.NamespacedName
+	for _, secret := range orphaned {
+		names = append(names, client.ObjectKeyFromObject(&secret))
+	}
+	return names, nil
+}
+
+// listOrphanedSecrets returns the secrets FindOrphanedSecrets finds for the reconciler, listing the namespaces
+// and secrets with its KubeClient
+func listOrphanedSecrets(ctx context.Context, r *ClusterPoolsReconciler) ([]corev1.Secret, error) {
+	labelKey, labelValue := getNamespaceLabel(r)
+
+	namespaces, err := r.KubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: labelKey + "=" + labelValue})
+	if err != nil {
+		return nil, err
+	}
+
+	opts := OrphanedSecretOptions{
+		Namespace:                 r.Namespace,
+		SecretNameResolver:        r.SecretNameResolver,
+		ExtraSecretRefPaths:       r.ExtraSecretRefPaths,
+		CrossNamespaceRefCounting: r.CrossNamespaceRefCounting,
+	}
+	return findOrphanedSecrets(ctx, r.Client, opts, namespaces.Items, func(namespace string) ([]corev1.Secret, error) {
+		secrets, err := r.KubeClient.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelKey})
+		if err != nil {
+			return nil, err
+		}
+		return secrets.Items, nil
+	})
+}
+
+// findOrphanedSecrets returns the labeled secrets of the namespaces, as listed by listSecrets, that none of the
+// cluster pools of their namespace references, or of any namespace with CrossNamespaceRefCounting
+func findOrphanedSecrets(ctx context.Context, c client.Reader, opts OrphanedSecretOptions, namespaces []corev1.Namespace,
+	listSecrets func(namespace string) ([]corev1.Secret, error)) ([]corev1.Secret, error) {
+
+	// Compared by name against the pools of the whole cluster, as deleteResources counts the references
+	var allPools *hivev1.ClusterPoolList
+	if opts.CrossNamespaceRefCounting && opts.Namespace == "" {
+		allPools = &hivev1.ClusterPoolList{}
+		if err := c.List(ctx, allPools); err != nil {
+			return nil, err
+		}
+	}
+
+	var orphaned []corev1.Secret
+	for _, ns := range namespaces {
+		if opts.Namespace != "" && ns.Name != opts.Namespace {
+			continue
+		}
+
+		cps := allPools
+		if cps == nil {
+			cps = &hivev1.ClusterPoolList{}
+			if err := c.List(ctx, cps, client.InNamespace(ns.Name)); err != nil {
+				return nil, err
+			}
+		}
+		secrets, err := listSecrets(ns.Name)
+		if err != nil {
+			return nil, err
+		}
+		orphaned = append(orphaned, unreferencedSecrets(opts.SecretNameResolver, opts.ExtraSecretRefPaths, cps.Items, secrets)...)
+	}
+
+	return orphaned, nil
+}
+
+// orphanCounter publishes the number of orphaned secrets every OrphanMetricsInterval, once the manager is
+// elected leader
+type orphanCounter struct {
+	r *ClusterPoolsReconciler
+}
+
+func (o *orphanCounter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(o.r.OrphanMetricsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := countOrphanedSecrets(ctx, o.r); err != nil {
+				o.r.Log.V(WARN).Info("Counting orphaned secrets failed", "error", err.Error())
+			}
+		}
+	}
+}
+
+func (o *orphanCounter) NeedLeaderElection() bool {
+	return true
+}
+
+// countOrphanedSecrets sets the orphaned secrets gauge to the number of orphaned secrets, without deleting any.
+// Unlike the sweep, recently created secrets are counted too, as are secrets kept while cleanup is disabled.
+// References are counted like the sweep counts them, across namespaces with CrossNamespaceRefCounting.
+func countOrphanedSecrets(ctx context.Context, r *ClusterPoolsReconciler) (int, error) {
+	orphaned, err := listOrphanedSecrets(ctx, r)
+	if err != nil {
+		return 0, err
+	}
+	orphanedSecrets.Set(float64(len(orphaned)))
+	return len(orphaned), nil
+}
@@ -0,0 +1,285 @@
+package clusterpools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestFindOrphanedSecrets(t *testing.T) {
+
+	ctx := context.Background()
+	c := GetClusterPoolsReconciler().Client
+
+	c.Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}))
+	c.Create(ctx, getNamespace("unmanaged", nil))
+	c.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	// secret01-03 are referenced by the pool
+	for _, secret := range []*corev1.Secret{getSecret(CP_NAMESPACE, "secret01"), getSecret(CP_NAMESPACE, "secret03"),
+		getSecret(CP_NAMESPACE, "orphan"), getSecret("unmanaged", "ignored")} {
+		secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+		c.Create(ctx, secret)
+	}
+	c.Create(ctx, getSecret(CP_NAMESPACE, "unlabeled"))
+
+	orphaned, err := FindOrphanedSecrets(ctx, c, OrphanedSecretOptions{})
+
+	assert.Nil(t, err, "nil, when the secrets were listed")
+	assert.Equal(t, []types.NamespacedName{{Namespace: CP_NAMESPACE, Name: "orphan"}}, orphaned,
+		"only the labeled, unreferenced secret of a managed namespace is orphaned")
+	assert.Nil(t, c.Get(ctx, orphaned[0], &corev1.Secret{}), "orphaned secrets are not deleted")
+}
+
+func TestFindOrphanedSecretsNoPools(t *testing.T) {
+
+	ctx := context.Background()
+	c := GetClusterPoolsReconciler().Client
+
+	c.Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}))
+	for _, name := range []string{"secret01", "secret02"} {
+		secret := getSecret(CP_NAMESPACE, name)
+		secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+		c.Create(ctx, secret)
+	}
+
+	orphaned, err := FindOrphanedSecrets(ctx, c, OrphanedSecretOptions{})
+
+	assert.Nil(t, err, "nil, when the secrets were listed")
+	assert.Len(t, orphaned, 2, "every labeled secret is orphaned once its pools are gone")
+}
+
+func TestFindOrphanedSecretsOptions(t *testing.T) {
+
+	ctx := context.Background()
+	c := GetClusterPoolsReconciler().Client
+
+	c.Create(ctx, getNamespace(CP_NAMESPACE, map[string]string{"example.com/pools": "managed"}))
+	c.Create(ctx, getNamespace("other", map[string]string{"example.com/pools": "managed"}))
+	c.Create(ctx, getNamespace("default-label", map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}))
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{"release-image-secret": "secret05"}
+	c.Create(ctx, cp)
+	for _, key := range []types.NamespacedName{{Namespace: CP_NAMESPACE, Name: "orphan"}, {Namespace: CP_NAMESPACE, Name: "secret01-prod"},
+		{Namespace: CP_NAMESPACE, Name: "secret05-prod"}, {Namespace: "other", Name: "ignored"}} {
+		secret := getSecret(key.Namespace, key.Name)
+		secret.Labels = map[string]string{"example.com/pools": "managed"}
+		c.Create(ctx, secret)
+	}
+	secret := getSecret("default-label", "ignored")
+	secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+	c.Create(ctx, secret)
+
+	orphaned, err := FindOrphanedSecrets(ctx, c, OrphanedSecretOptions{
+		NamespaceLabel:      "example.com/pools",
+		NamespaceLabelValue: "managed",
+		Namespace:           CP_NAMESPACE,
+		SecretNameResolver:  suffixResolver,
+		ExtraSecretRefPaths: []string{RELEASE_IMAGE_SECRET_PATH},
+	})
+
+	assert.Nil(t, err, "nil, when the secrets were listed")
+	assert.Equal(t, []types.NamespacedName{{Namespace: CP_NAMESPACE, Name: "orphan"}}, orphaned,
+		"only the configured label and namespace are considered, resolved and extra refs are referenced")
+}
+
+func TestSweepOrphanedSecretsNamespaceLabel(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.EnableOrphanSweep = true
+	cpr.NamespaceLabel = "example.com/pools"
+	cpr.NamespaceLabelValue = "managed"
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{"example.com/pools": "managed"}), metav1.CreateOptions{})
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace("default-label", map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	secret := getSecret(CP_NAMESPACE, "orphan")
+	secret.Labels = map[string]string{"example.com/pools": "managed"}
+	secret.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, secret, metav1.CreateOptions{})
+	seedLabeledSecret(ctx, cpr, "default-label", "ignored", time.Hour)
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were swept")
+	assert.Equal(t, []string{CP_NAMESPACE + "/orphan"}, deleted, "only the namespaces and secrets with the configured label are swept")
+}
+
+func TestSweepOrphanedSecretsCrossNamespaceRefCounting(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.EnableOrphanSweep = true
+	cpr.CrossNamespaceRefCounting = true
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	// The pool of another namespace references secret03 by name
+	cpr.Create(ctx, GetClusterPool("other", CP_NAME, "aws"))
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret03", time.Hour)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan", time.Hour)
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were swept")
+	assert.Equal(t, []string{CP_NAMESPACE + "/orphan"}, deleted, "only the secret no pool of any namespace references is swept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "a secret referenced from another namespace is kept")
+
+	cpr.CrossNamespaceRefCounting = false
+	deleted, err = sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were swept")
+	assert.Equal(t, []string{CP_NAMESPACE + "/secret03"}, deleted, "without CrossNamespaceRefCounting only the pools of the namespace count")
+}
+
+func seedLabeledSecret(ctx context.Context, cpr *ClusterPoolsReconciler, namespace string, name string, age time.Duration) {
+	secret := getSecret(namespace, name)
+	secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+	secret.CreationTimestamp = metav1.NewTime(time.Now().Add(-age))
+	cpr.KubeClient.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+}
+
+func TestSweepOrphanedSecrets(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.EnableOrphanSweep = true
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	cpr.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret01", time.Hour)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan", time.Hour)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "young", time.Minute)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "unlabeled")
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were swept")
+	assert.Equal(t, []string{CP_NAMESPACE + "/orphan"}, deleted, "only the old, labeled, unreferenced secret is swept")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "orphan"), "orphaned secret is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "referenced secret is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "young"), "secret younger than the sweep interval is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "unlabeled"), "unlabeled secret is kept")
+}
+
+func TestSweepOrphanedSecretsResolveName(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.EnableOrphanSweep = true
+	cpr.SecretNameResolver = suffixResolver
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	cpr.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret01-prod", time.Hour)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret01", time.Hour)
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were swept")
+	assert.Equal(t, []string{CP_NAMESPACE + "/secret01"}, deleted, "only the secret the resolver does not name is swept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01-prod"), "resolved secret of a live pool is kept")
+}
+
+func TestSweepOrphanedSecretsUnlabeledNamespace(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx, getNamespace(CP_NAMESPACE, nil), metav1.CreateOptions{})
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan", time.Hour)
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the namespace is not labeled")
+	assert.Empty(t, deleted, "nothing is swept in an unlabeled namespace")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "orphan"), "secret of an unlabeled namespace is kept")
+}
+
+func TestSweepOrphanedSecretsCleanupDisabled(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.DisableCleanup = true
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan", time.Hour)
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when cleanup is disabled")
+	assert.Empty(t, deleted, "nothing is swept when cleanup is disabled")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "orphan"), "orphaned secret is kept when cleanup is disabled")
+}
+
+func TestSweepOrphanedSecretsCleanupScope(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.CleanupScope = CLEANUP_SCOPE_PROVIDER_ONLY
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan", time.Hour)
+
+	deleted, err := sweepOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the cleanup scope skips the sweep")
+	assert.Empty(t, deleted, "nothing is swept outside the All cleanup scope")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "orphan"), "orphaned secret is kept outside the All cleanup scope")
+}
+
+func TestCountOrphanedSecrets(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret01", time.Hour)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan01", time.Hour)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan02", time.Minute)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "unlabeled")
+
+	count, err := countOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were counted")
+	assert.Equal(t, 2, count, "the labeled, unreferenced secrets are counted")
+	assert.Equal(t, float64(2), testutil.ToFloat64(orphanedSecrets), "the gauge reflects the orphaned secrets")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "orphan01"), "counted secrets are not deleted")
+
+	cpr.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Delete(ctx, "orphan01", metav1.DeleteOptions{})
+	countOrphanedSecrets(ctx, cpr)
+	assert.Equal(t, float64(1), testutil.ToFloat64(orphanedSecrets), "the gauge follows the orphaned secrets down")
+}
+
+func TestCountOrphanedSecretsCrossNamespaceRefCounting(t *testing.T) {
+
+	ctx := context.Background()
+	cpr := GetClusterPoolsReconciler()
+	cpr.CrossNamespaceRefCounting = true
+
+	cpr.KubeClient.CoreV1().Namespaces().Create(ctx,
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), metav1.CreateOptions{})
+	// The pool of another namespace references secret01 by name
+	cpr.Client.Create(ctx, GetClusterPool("other", CP_NAME, "aws"))
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "secret01", time.Hour)
+	seedLabeledSecret(ctx, cpr, CP_NAMESPACE, "orphan01", time.Hour)
+
+	count, err := countOrphanedSecrets(ctx, cpr)
+
+	assert.Nil(t, err, "nil, when the orphaned secrets were counted")
+	assert.Equal(t, 1, count, "a secret referenced from another namespace is not counted")
+	assert.Equal(t, float64(1), testutil.ToFloat64(orphanedSecrets), "the gauge reflects the orphaned secrets")
+}
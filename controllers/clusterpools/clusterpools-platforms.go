@@ -0,0 +1,209 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+)
+
+// PlatformSecretExtractor returns the secrets a cluster pool references for its platform. Supporting a new
+// platform takes an implementation added to platformExtractors.
+type PlatformSecretExtractor interface {
+	// Platform is the platform name used in logs, and the cpType of getCPDetails
+	Platform() string
+	// Matches reports whether the cluster pool runs on the platform
+	Matches(cp *hivev1.ClusterPool) bool
+	// ProviderSecretNames returns the cloud provider credentials secrets of a matching cluster pool
+	ProviderSecretNames(cp *hivev1.ClusterPool) []string
+	// ExtraSecrets returns the other platform specific secrets of a matching cluster pool, like CA certificates
+	ExtraSecrets(cp *hivev1.ClusterPool) []secretRef
+}
+
+// platformExtractors is the registry of platform extractors, in the order a cluster pool's platform is detected
+var platformExtractors = []PlatformSecretExtractor{
+	awsExtractor{},
+	gcpExtractor{},
+	azureExtractor{},
+	openstackExtractor{},
+	vsphereExtractor{},
+	ibmcloudExtractor{},
+	nutanixExtractor{},
+	ovirtExtractor{},
+	baremetalExtractor{},
+}
+
+type awsExtractor struct{}
+
+func (awsExtractor) Platform() string { return "aws" }
+
+func (awsExtractor) Matches(cp *hivev1.ClusterPool) bool { return cp.Spec.Platform.AWS != nil }
+
+func (awsExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.AWS.CredentialsSecretRef.Name}
+}
+
+func (awsExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef { return nil }
+
+// gcpExtractor covers GCP pools with service account keys and with workload identity alike. The Hive Platform
+// only references the credentials secret, which holds the key or the workload identity configuration.
+type gcpExtractor struct{}
+
+func (gcpExtractor) Platform() string { return "gcp" }
+
+func (gcpExtractor) Matches(cp *hivev1.ClusterPool) bool { return cp.Spec.Platform.GCP != nil }
+
+func (gcpExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.GCP.CredentialsSecretRef.Name}
+}
+
+func (gcpExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef { return nil }
+
+// azureExtractor covers every Azure cloud, Government and China included. The Hive Platform only references
+// the credentials secret, sovereign clouds are selected with CloudName rather than an extra config secret.
+type azureExtractor struct{}
+
+func (azureExtractor) Platform() string { return "azure" }
+
+func (azureExtractor) Matches(cp *hivev1.ClusterPool) bool { return cp.Spec.Platform.Azure != nil }
+
+func (azureExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.Azure.CredentialsSecretRef.Name}
+}
+
+func (azureExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef { return nil }
+
+// openstackExtractor and vsphereExtractor return the CA trust bundle as a certificates secret. The Hive Platforms
+// reference CA certificates by secret only, no ConfigMap, so they are reference counted like the other secrets.
+type openstackExtractor struct{}
+
+func (openstackExtractor) Platform() string { return "openstack" }
+
+func (openstackExtractor) Matches(cp *hivev1.ClusterPool) bool {
+	return cp.Spec.Platform.OpenStack != nil
+}
+
+func (openstackExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.OpenStack.CredentialsSecretRef.Name}
+}
+
+func (openstackExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef {
+	if cp.Spec.Platform.OpenStack.CertificatesSecretRef == nil {
+		return nil
+	}
+	return []secretRef{{SECRET_TYPE_CERTIFICATES, cp.Spec.Platform.OpenStack.CertificatesSecretRef.Name}}
+}
+
+type vsphereExtractor struct{}
+
+func (vsphereExtractor) Platform() string { return "vsphere" }
+
+func (vsphereExtractor) Matches(cp *hivev1.ClusterPool) bool { return cp.Spec.Platform.VSphere != nil }
+
+func (vsphereExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.VSphere.CredentialsSecretRef.Name}
+}
+
+func (vsphereExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef {
+	return []secretRef{{SECRET_TYPE_CERTIFICATES, cp.Spec.Platform.VSphere.CertificatesSecretRef.Name}}
+}
+
+type ibmcloudExtractor struct{}
+
+func (ibmcloudExtractor) Platform() string { return "ibmcloud" }
+
+func (ibmcloudExtractor) Matches(cp *hivev1.ClusterPool) bool {
+	return cp.Spec.Platform.IBMCloud != nil
+}
+
+func (ibmcloudExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.IBMCloud.CredentialsSecretRef.Name}
+}
+
+func (ibmcloudExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef { return nil }
+
+type nutanixExtractor struct{}
+
+func (nutanixExtractor) Platform() string { return "nutanix" }
+
+func (nutanixExtractor) Matches(cp *hivev1.ClusterPool) bool { return cp.Spec.Platform.Nutanix != nil }
+
+func (nutanixExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.Nutanix.CredentialsSecretRef.Name}
+}
+
+// ExtraSecrets returns the Prism Central CA certificates secret, skipped by getCPExtraSecrets when unset
+func (nutanixExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef {
+	return []secretRef{{SECRET_TYPE_CERTIFICATES, cp.Spec.Platform.Nutanix.CertificatesSecretRef.Name}}
+}
+
+type ovirtExtractor struct{}
+
+func (ovirtExtractor) Platform() string { return "ovirt" }
+
+func (ovirtExtractor) Matches(cp *hivev1.ClusterPool) bool { return cp.Spec.Platform.Ovirt != nil }
+
+func (ovirtExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string {
+	return []string{cp.Spec.Platform.Ovirt.CredentialsSecretRef.Name}
+}
+
+// ExtraSecrets returns the oVirt engine CA certificates secret, skipped by getCPExtraSecrets when unset
+func (ovirtExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef {
+	return []secretRef{{SECRET_TYPE_CERTIFICATES, cp.Spec.Platform.Ovirt.CertificatesSecretRef.Name}}
+}
+
+// baremetalExtractor has no cloud provider secret, only the libvirt SSH private key
+type baremetalExtractor struct{}
+
+func (baremetalExtractor) Platform() string { return "baremetal" }
+
+func (baremetalExtractor) Matches(cp *hivev1.ClusterPool) bool {
+	return cp.Spec.Platform.BareMetal != nil
+}
+
+func (baremetalExtractor) ProviderSecretNames(cp *hivev1.ClusterPool) []string { return nil }
+
+func (baremetalExtractor) ExtraSecrets(cp *hivev1.ClusterPool) []secretRef {
+	return []secretRef{{SECRET_TYPE_SSH, cp.Spec.Platform.BareMetal.LibvirtSSHPrivateKeySecretRef.Name}}
+}
+
+// getPlatformExtractor returns the extractor of the cluster pool's platform, nil when none matches
+func getPlatformExtractor(cp *hivev1.ClusterPool) PlatformSecretExtractor {
+	for _, extractor := range platformExtractors {
+		if extractor.Matches(cp) {
+			return extractor
+		}
+	}
+	return nil
+}
+
+// getCPDetails returns the platform type and provider secret of the cluster pool. Spec and Platform are values
+// in the Hive API, so a pool without any platform set only has nil platform pointers, which no extractor
+// matches, and is CP_TYPE_NONE.
+func getCPDetails(cp hivev1.ClusterPool) (cpType string, providerSecretName string) {
+	extractor := getPlatformExtractor(&cp)
+	if extractor == nil {
+		return CP_TYPE_NONE, ""
+	}
+	names := extractor.ProviderSecretNames(&cp)
+	if len(names) == 0 {
+		// Bare metal, agent and platform-agnostic pools have no cloud provider secret
+		return CP_TYPE_NONE, ""
+	}
+	return extractor.Platform(), names[0]
+}
+
+// getCPExtraSecrets returns the platform specific secrets of a cluster pool, skipping empty refs
+func getCPExtraSecrets(cp hivev1.ClusterPool) []secretRef {
+	var secrets []secretRef
+	for _, extractor := range platformExtractors {
+		if !extractor.Matches(&cp) {
+			continue
+		}
+		for _, secret := range extractor.ExtraSecrets(&cp) {
+			if secret.name != "" {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	return secrets
+}
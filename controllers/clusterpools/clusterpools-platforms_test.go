@@ -0,0 +1,269 @@
+package clusterpools
+
+import (
+	"context"
+	"testing"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/openshift/hive/apis/hive/v1/azure"
+	"github.com/openshift/hive/apis/hive/v1/gcp"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPlatformExtractors(t *testing.T) {
+
+	tests := []struct {
+		poolType string
+		platform string
+		provider []string
+		extra    []secretRef
+	}{
+		{"aws", "aws", []string{"secret03"}, nil},
+		{"gcp", "gcp", []string{"secret03"}, nil},
+		{"azure", "azure", []string{"secret03"}, nil},
+		{"openstack", "openstack", []string{"secret03"}, nil},
+		{"vsphere", "vsphere", []string{"secret03"}, []secretRef{{SECRET_TYPE_CERTIFICATES, "secret04"}}},
+		{"ibmcloud", "ibmcloud", []string{"secret03"}, nil},
+		{"nutanix", "nutanix", []string{"secret03"}, []secretRef{{SECRET_TYPE_CERTIFICATES, "secret04"}}},
+		{"ovirt", "ovirt", []string{"secret03"}, []secretRef{{SECRET_TYPE_CERTIFICATES, "secret04"}}},
+		{"baremetal", "baremetal", nil, []secretRef{{SECRET_TYPE_SSH, "secret05"}}},
+	}
+
+	for _, tt := range tests {
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, tt.poolType)
+
+		extractor := getPlatformExtractor(cp)
+		if !assert.NotNil(t, extractor, "an extractor matches "+tt.poolType) {
+			continue
+		}
+		assert.Equal(t, tt.platform, extractor.Platform(), "the registry picks the "+tt.poolType+" extractor")
+		assert.Equal(t, tt.provider, extractor.ProviderSecretNames(cp), "provider secrets of "+tt.poolType)
+		assert.Equal(t, tt.extra, extractor.ExtraSecrets(cp), "platform secrets of "+tt.poolType)
+
+		for _, other := range platformExtractors {
+			if other.Platform() != tt.platform {
+				assert.False(t, other.Matches(cp), other.Platform()+" does not match a "+tt.poolType+" pool")
+			}
+		}
+	}
+}
+
+func TestPlatformExtractorsNone(t *testing.T) {
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "none")
+
+	assert.Nil(t, getPlatformExtractor(cp), "no extractor matches a platform-agnostic pool")
+
+	cpType, providerSecretName := getCPDetails(*cp)
+	assert.Equal(t, CP_TYPE_NONE, cpType, "a platform-agnostic pool has no cpType")
+	assert.Empty(t, providerSecretName, "a platform-agnostic pool has no provider secret")
+}
+
+func TestReconcileClusterPoolDeleteNoPlatform(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := &hivev1.ClusterPool{ObjectMeta: v1.ObjectMeta{Name: CP_NAME, Namespace: CP_NAMESPACE}}
+	cpType, providerSecretName := getCPDetails(*cp)
+	assert.Equal(t, CP_TYPE_NONE, cpType, "a pool without a platform has no cpType")
+	assert.Empty(t, providerSecretName, "a pool without a platform has no provider secret")
+	assert.Empty(t, getCPSecretRefs(*cp), "a pool without a platform or refs references no secrets")
+
+	createDeletingClusterPool(ctx, cpr, cp)
+
+	assert.NotPanics(t, func() {
+		_, err := cpr.Reconcile(ctx, getRequest())
+		assert.Nil(t, err, "nil, when a pool without a platform is deleted")
+	}, "a pool without a platform is cleaned up")
+}
+
+func TestGetCPDetails(t *testing.T) {
+
+	tests := []struct {
+		poolType       string
+		cpType         string
+		providerSecret string
+	}{
+		{"aws", "aws", "secret03"},
+		{"gcp", "gcp", "secret03"},
+		{"azure", "azure", "secret03"},
+		{"openstack", "openstack", "secret03"},
+		{"vsphere", "vsphere", "secret03"},
+		{"ibmcloud", "ibmcloud", "secret03"},
+		{"nutanix", "nutanix", "secret03"},
+		{"ovirt", "ovirt", "secret03"},
+		{"baremetal", CP_TYPE_NONE, ""},
+	}
+
+	for _, tt := range tests {
+		cpType, providerSecretName := getCPDetails(*GetClusterPool(CP_NAMESPACE, CP_NAME, tt.poolType))
+		assert.Equal(t, tt.cpType, cpType, "cpType of "+tt.poolType)
+		assert.Equal(t, tt.providerSecret, providerSecretName, "provider secret of "+tt.poolType)
+	}
+}
+
+func TestReconcileClusterPoolDeleteAzureGovernment(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "azure")
+	cp.Spec.Platform.Azure.CloudName = azure.USGovernmentCloud
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	assert.Equal(t, []secretRef{
+		{SECRET_TYPE_PULL, "secret01"},
+		{SECRET_TYPE_INSTALLCONFIG, "secret02"},
+		{SECRET_TYPE_PROVIDER, "secret03"},
+	}, getCPSecretRefs(*cp), "a Government cloud pool references only its credentials secret beyond pull and install-config")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the Government cloud credentials secret is deleted")
+}
+
+func TestReconcileClusterPoolDeleteGcpPrivateServiceConnect(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "gcp")
+	cp.Spec.Platform.GCP.PrivateServiceConnect = &gcp.PrivateServiceConnect{Enabled: true}
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	assert.Equal(t, []secretRef{
+		{SECRET_TYPE_PULL, "secret01"},
+		{SECRET_TYPE_INSTALLCONFIG, "secret02"},
+		{SECRET_TYPE_PROVIDER, "secret03"},
+	}, getCPSecretRefs(*cp), "a GCP pool references only its credentials secret beyond pull and install-config")
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "unshared secret "+name+" of the GCP pool is deleted")
+	}
+}
+
+func TestReconcileClusterPoolDeleteVsphereCertificates(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "vsphere")
+	sibling.Spec.Platform.VSphere.CredentialsSecretRef.Name = "secret13"
+	sibling.Spec.Platform.VSphere.CertificatesSecretRef.Name = "secret14"
+	cpr.Client.Create(ctx, sibling, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret03", "secret04", "secret14")
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "the unshared vCenter CA secret is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret14"), "the CA secret of the sibling is kept")
+}
+
+func TestReconcileClusterPoolDeleteNutanix(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03", "secret04")
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "nutanix"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "Prism credentials secret is deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "Prism Central CA secret is deleted")
+}
+
+func TestReconcileClusterPoolDeleteNutanixShared(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "nutanix"), &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03", "secret04")
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "nutanix"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "shared Prism credentials secret is kept")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "shared Prism Central CA secret is kept")
+}
+
+func TestGetCPExtraSecretsNutanixWithoutCA(t *testing.T) {
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "nutanix")
+	cp.Spec.Platform.Nutanix.CertificatesSecretRef.Name = ""
+
+	assert.Empty(t, getCPExtraSecrets(*cp), "a Nutanix pool without a Prism Central CA secret has no platform secrets")
+}
+
+func TestReconcileClusterPoolDeleteOvirt(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03", "secret04")
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "ovirt"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "oVirt engine credentials secret is deleted")
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "oVirt CA secret is deleted")
+}
+
+func TestReconcileClusterPoolDeleteOvirtShared(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp2 := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "ovirt")
+	cp2.Spec.Platform.Ovirt.CredentialsSecretRef.Name = "secret13"
+	cpr.Client.Create(ctx, cp2, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03", "secret04")
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "ovirt"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "unshared oVirt engine credentials secret is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret04"), "shared oVirt CA secret is kept")
+}
+
+func TestGetCPExtraSecretsOvirtWithoutCA(t *testing.T) {
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "ovirt")
+	cp.Spec.Platform.Ovirt.CertificatesSecretRef.Name = ""
+
+	assert.Empty(t, getCPExtraSecrets(*cp), "an oVirt pool without a CA secret has no platform secrets")
+}
+
+func TestReconcileClusterPoolDeleteProviderSecretSharedAcrossPlatforms(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cpAzure := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "azure")
+	cpAzure.Spec.PullSecretRef.Name = "secret11"
+	cpAzure.Spec.InstallConfigSecretTemplateRef.Name = "secret12"
+	cpr.Client.Create(ctx, cpAzure, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, _, err := deleteResources(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+
+	assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret01"), "unshared pull secret is deleted")
+	assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, "secret03"), "the AWS provider secret referenced by an Azure pool is kept")
+}
@@ -0,0 +1,121 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// refCache keeps recent cluster pool lists per namespace, "" for the whole cluster, so the pools deleted in a
+// burst reuse one List for their sibling reference counting
+type refCache struct {
+	mu      sync.Mutex
+	entries map[string]refCacheEntry
+}
+
+type refCacheEntry struct {
+	pools   []hivev1.ClusterPool
+	expires time.Time
+}
+
+func (c *refCache) get(key string) ([]hivev1.ClusterPool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.pools, true
+}
+
+func (c *refCache) put(key string, pools []hivev1.ClusterPool, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]refCacheEntry{}
+	}
+	c.entries[key] = refCacheEntry{pools: pools, expires: time.Now().Add(ttl)}
+}
+
+// invalidate drops the lists a pool in the namespace may have changed
+func (c *refCache) invalidate(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, namespace)
+	delete(c.entries, "")
+}
+
+// forget removes a pool that is gone from the cached lists, so it no longer keeps its secrets
+func (c *refCache) forget(cp client.Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if key != "" && key != cp.GetNamespace() {
+			continue
+		}
+		var pools []hivev1.ClusterPool
+		for _, pool := range entry.pools {
+			if pool.Namespace != cp.GetNamespace() || pool.Name != cp.GetName() {
+				pools = append(pools, pool)
+			}
+		}
+		c.entries[key] = refCacheEntry{pools: pools, expires: entry.expires}
+	}
+}
+
+// listClusterPools returns the cluster pools deleteResources counts references against. With RefCacheTTL, a
+// recent list is reused as long as it keeps everything the cluster pool references. When it would let the pool
+// delete a secret or its namespace, the pools are listed again, so a stale list never deletes what a newer pool uses.
+func listClusterPools(ctx context.Context, r *ClusterPoolsReconciler, cp *hivev1.ClusterPool, listOptions *client.ListOptions) ([]hivev1.ClusterPool, error) {
+	if r.RefCacheTTL > 0 {
+		if pools, found := r.refCache.get(listOptions.Namespace); found && !wouldDelete(r.SecretNameResolver, r.ExtraSecretRefPaths, cp, pools) {
+			return pools, nil
+		}
+	}
+
+	var cps hivev1.ClusterPoolList
+	if err := r.List(ctx, &cps, listOptions); err != nil {
+		return nil, err
+	}
+	if r.RefCacheTTL > 0 {
+		r.refCache.put(listOptions.Namespace, cps.Items, r.RefCacheTTL)
+	}
+	return cps.Items, nil
+}
+
+// wouldDelete reports whether counting against the pools leaves the cluster pool the last one of its namespace,
+// or with a secret no sibling references
+func wouldDelete(resolver SecretNameResolver, paths []string, cp *hivev1.ClusterPool, pools []hivev1.ClusterPool) bool {
+	otherPools := 0
+	siblingRefs := map[string]bool{}
+	for _, pool := range pools {
+		if pool.Namespace == cp.Namespace && pool.Name == cp.Name {
+			continue
+		}
+		if pool.Namespace == cp.Namespace {
+			otherPools++
+		}
+		for _, name := range getReferencedSecretNames(resolver, paths, pool) {
+			siblingRefs[name] = true
+		}
+	}
+	if otherPools == 0 {
+		return true
+	}
+
+	for _, name := range getReferencedSecretNames(resolver, paths, *cp) {
+		if !siblingRefs[name] {
+			return true
+		}
+	}
+	return false
+}
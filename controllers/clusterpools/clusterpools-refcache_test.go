@@ -0,0 +1,144 @@
+package clusterpools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// getListCountingClusterPoolsReconciler counts the ClusterPool lists the reconciler makes
+func getListCountingClusterPoolsReconciler(lists *int) *ClusterPoolsReconciler {
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client = clientfake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&hivev1.ClusterPool{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				if _, ok := list.(*hivev1.ClusterPoolList); ok {
+					*lists++
+				}
+				return c.List(ctx, list, opts...)
+			},
+		}).Build()
+	return cpr
+}
+
+// deletePoolsSharingSecrets deletes pools that all share the same secrets, one reconcile each
+func deletePoolsSharingSecrets(ctx context.Context, cpr *ClusterPoolsReconciler, pools int) {
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	for i := 0; i < pools; i++ {
+		createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, fmt.Sprintf("%s%02d", CP_NAME, i), "aws"))
+	}
+	for i := 0; i < pools; i++ {
+		cpr.Reconcile(ctx, getRequestWithNamespaceName(CP_NAMESPACE, fmt.Sprintf("%s%02d", CP_NAME, i)))
+	}
+}
+
+func TestDeleteResourcesRefCacheListCalls(t *testing.T) {
+
+	ctx := context.Background()
+
+	uncachedLists := 0
+	uncached := getListCountingClusterPoolsReconciler(&uncachedLists)
+	deletePoolsSharingSecrets(ctx, uncached, 10)
+
+	cachedLists := 0
+	cached := getListCountingClusterPoolsReconciler(&cachedLists)
+	cached.RefCacheTTL = time.Minute
+	deletePoolsSharingSecrets(ctx, cached, 10)
+
+	assert.Equal(t, 10, uncachedLists, "every deleted pool lists its siblings without the cache")
+	assert.Equal(t, 2, cachedLists, "the cache lists once for the burst and once more for the last pool")
+
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.False(t, secretExists(ctx, cached, CP_NAMESPACE, name), "the last pool still deletes the shared secret: "+name)
+	}
+}
+
+func TestDeleteResourcesRefCacheStale(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.RefCacheTTL = time.Minute
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cpr.Client.Create(ctx, cp, &client.CreateOptions{})
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	// A cached list from before the second pool was created, whose create event has not arrived yet
+	cpr.refCache.put(CP_NAMESPACE, []hivev1.ClusterPool{*cp}, time.Minute)
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+
+	_, _, err := deleteResources(ctx, cpr, cp)
+	assert.Nil(t, err, "nil, when clusterPool delete was successful")
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.True(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "a stale cache does not delete a secret the new pool references: "+name)
+	}
+}
+
+func TestEventFilterRefCacheInvalidation(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+	filter := eventFilter(cpr)
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cpr.refCache.put(CP_NAMESPACE, []hivev1.ClusterPool{*cp}, time.Minute)
+
+	touched := cp.DeepCopy()
+	touched.Labels = map[string]string{"hive": "touched"}
+	filter.Update(event.UpdateEvent{ObjectOld: cp, ObjectNew: touched})
+	_, found := cpr.refCache.get(CP_NAMESPACE)
+	assert.True(t, found, "an update that keeps the secret refs keeps the cache")
+
+	changed := cp.DeepCopy()
+	changed.Spec.PullSecretRef.Name = "secret09"
+	filter.Update(event.UpdateEvent{ObjectOld: cp, ObjectNew: changed})
+	_, found = cpr.refCache.get(CP_NAMESPACE)
+	assert.False(t, found, "an update changing the secret refs invalidates the cache")
+
+	cpr.refCache.put(CP_NAMESPACE, []hivev1.ClusterPool{*cp}, time.Minute)
+	filter.Create(event.CreateEvent{Object: GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")})
+	_, found = cpr.refCache.get(CP_NAMESPACE)
+	assert.False(t, found, "a created pool invalidates the cache")
+
+	cpr.refCache.put(CP_NAMESPACE, []hivev1.ClusterPool{*cp}, time.Minute)
+	filter.Delete(event.DeleteEvent{Object: cp})
+	pools, _ := cpr.refCache.get(CP_NAMESPACE)
+	assert.Empty(t, pools, "a deleted pool is removed from the cache")
+}
+
+func TestRefCacheExpires(t *testing.T) {
+
+	var c refCache
+	c.put(CP_NAMESPACE, []hivev1.ClusterPool{*GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	_, found := c.get(CP_NAMESPACE)
+	assert.False(t, found, "an expired list is not reused")
+}
+
+func BenchmarkDeleteResourcesRefCache(b *testing.B) {
+
+	ctx := context.Background()
+
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		b.Run(fmt.Sprintf("ttl=%s", ttl), func(b *testing.B) {
+			lists := 0
+			for i := 0; i < b.N; i++ {
+				cpr := getListCountingClusterPoolsReconciler(&lists)
+				cpr.Log = logr.Discard()
+				cpr.RefCacheTTL = ttl
+				deletePoolsSharingSecrets(ctx, cpr, 50)
+			}
+			b.ReportMetric(float64(lists)/float64(b.N), "lists/op")
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// RESYNC_JITTER_FACTOR spreads each resync over up to a tenth of the ResyncInterval more, so controller instances
+// restarted together do not list and reconcile all of their pools at the same time
+const RESYNC_JITTER_FACTOR = 0.1
+
+// poolResyncer enqueues every watched cluster pool once at startup, and with ResyncInterval again each interval,
+// once the manager is elected leader. The startup backfill adds the finalizer to pools that existed before the
+// controller was deployed, without waiting for an update. The resync
+// retries a cleanup that failed or was missed while the controller was down, without a new event.
+type poolResyncer struct {
+	r      *ClusterPoolsReconciler
+	events chan event.GenericEvent
+}
+
+func (p *poolResyncer) Start(ctx context.Context) error {
+	if err := resyncPools(ctx, p.r, p.events); err != nil {
+		p.r.Log.V(WARN).Info("Cluster pool startup backfill failed", "error", err.Error())
+	}
+	if p.r.ResyncInterval <= 0 {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait.Jitter(p.r.ResyncInterval, RESYNC_JITTER_FACTOR)):
+			if err := resyncPools(ctx, p.r, p.events); err != nil {
+				p.r.Log.V(WARN).Info("Cluster pool resync failed", "error", err.Error())
+			}
+		}
+	}
+}
+
+func (p *poolResyncer) NeedLeaderElection() bool {
+	return true
+}
+
+// resyncPools sends a generic event per watched cluster pool. The events bypass the event filter, so the pools are
+// filtered with watchesPool here.
+func resyncPools(ctx context.Context, r *ClusterPoolsReconciler, events chan<- event.GenericEvent) error {
+	var cps hivev1.ClusterPoolList
+	if err := r.List(ctx, &cps, client.InNamespace(r.Namespace)); err != nil {
+		return err
+	}
+
+	// A resync checks every pool again, also those already reconciled at their generation
+	r.reconciledGenerations.Clear()
+
+	queued := 0
+	for i := range cps.Items {
+		if !watchesPool(r, &cps.Items[i]) {
+			continue
+		}
+		select {
+		case events <- event.GenericEvent{Object: &cps.Items[i]}:
+			queued++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r.Log.V(DEBUG).Info("Resynced cluster pools", "clusterPools", queued)
+	return nil
+}
@@ -0,0 +1,98 @@
+package clusterpools
+
+import (
+	"context"
+	"testing"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestResyncPoolsCleansUpMissedDelete(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	// The pool was deleted while the controller was down and its secrets were never cleaned up
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	createDeletingClusterPool(ctx, cpr, cp)
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	events := make(chan event.GenericEvent, 1)
+	err := resyncPools(ctx, cpr, events)
+	assert.Nil(t, err, "nil, when the pools were resynced")
+
+	if !assert.Len(t, events, 1, "the deleting pool is enqueued") {
+		return
+	}
+	queued := <-events
+	_, err = cpr.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(queued.Object)})
+	assert.Nil(t, err, "nil, when the resynced pool was cleaned up")
+
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		assert.False(t, secretExists(ctx, cpr, CP_NAMESPACE, name), "secret missed during the downtime is cleaned up: "+name)
+	}
+}
+
+func TestResyncPoolsWatchLabelSelector(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.WatchLabelSelector = labels.SelectorFromSet(labels.Set{"console": "true"})
+
+	matching := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	matching.Labels = map[string]string{"console": "true"}
+	cpr.Client.Create(ctx, matching, &client.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+
+	events := make(chan event.GenericEvent, 2)
+	err := resyncPools(ctx, cpr, events)
+	assert.Nil(t, err, "nil, when the pools were resynced")
+
+	if assert.Len(t, events, 1, "only the watched pool is enqueued") {
+		assert.Equal(t, CP_NAME, (<-events).Object.GetName(), "the matching pool is enqueued")
+	}
+}
+
+func TestPoolResyncerStartupBackfill(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cpr := GetClusterPoolsReconciler()
+
+	// The pools existed before the controller was deployed
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws"), &client.CreateOptions{})
+
+	events := make(chan event.GenericEvent, 2)
+	done := make(chan error)
+	go func() {
+		done <- (&poolResyncer{r: cpr, events: events}).Start(ctx)
+	}()
+
+	assert.Nil(t, <-done, "the startup backfill returns without a ResyncInterval")
+
+	if !assert.Len(t, events, 2, "every pre-existing pool is enqueued") {
+		return
+	}
+	for len(events) > 0 {
+		queued := <-events
+		_, err := cpr.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(queued.Object)})
+		assert.Nil(t, err, "nil, when the backfilled pool was reconciled")
+	}
+
+	for _, name := range []string{CP_NAME, CP_NAME + "02"} {
+		cp := &hivev1.ClusterPool{}
+		cpr.Client.Get(ctx, getNamespaceName(CP_NAMESPACE, name), cp)
+		assert.True(t, controllerutil.ContainsFinalizer(cp, FINALIZER), "the pre-existing pool gets the finalizer: "+name)
+	}
+}
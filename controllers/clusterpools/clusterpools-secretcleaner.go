@@ -0,0 +1,395 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretCleaner deletes the secrets of a removed cluster pool that none of its sibling cluster pools reference.
+// Secrets are read and deleted with the kubernetes clientset, like the rest of the controller, so no secret
+// informer is started.
+type SecretCleaner struct {
+	KubeClient kubernetes.Interface
+	Log        logr.Logger
+
+	// ProviderOnly keeps every secret the cluster pool references under another type than provider or certificates
+	ProviderOnly bool
+
+	// KeepProvider keeps the provider secret, for cluster deployments that still deprovision with it
+	KeepProvider bool
+
+	// ManagedLabel, when set, keeps the secrets that have neither this label key nor the MANAGED annotation
+	ManagedLabel string
+
+	// Retries retries a secret deletion failing with a retryable error this many times, with backoff
+	Retries int
+
+	// ResolveName, when set, expands each secret ref into the names of the secrets it stands for
+	ResolveName SecretNameResolver
+
+	// ExtraRefPaths are the field paths of the extra secrets the cluster pools reference, see getExtraSecretRefs
+	ExtraRefPaths []string
+
+	// OnDelete, when set, is called after each secret is deleted
+	OnDelete func(cp *hivev1.ClusterPool, secretType string, name string)
+}
+
+// SecretNameResolver returns the names of the secrets a secret ref of the cluster pool stands for, for naming
+// schemes where the secret objects are not named after the refs
+type SecretNameResolver func(cp *hivev1.ClusterPool, refName string) []string
+
+// resolveSecretNames returns the secret names the refs of the cluster pool stand for, the refs themselves
+// without a resolver
+func resolveSecretNames(resolver SecretNameResolver, cp *hivev1.ClusterPool, refNames []string) []string {
+	if resolver == nil {
+		return refNames
+	}
+	var names []string
+	for _, refName := range refNames {
+		names = append(names, resolver(cp, refName)...)
+	}
+	return names
+}
+
+// getReferencedSecretNames returns the names of the secrets the cluster pool references, its own refs and those
+// at the extra field paths, expanded by the resolver. Every check whether a secret is referenced goes through it.
+func getReferencedSecretNames(resolver SecretNameResolver, paths []string, cp hivev1.ClusterPool) []string {
+	return resolveSecretNames(resolver, &cp, append(getSecretRefNames(cp), getExtraSecretRefNames(paths, cp)...))
+}
+
+// CleanupForPool deletes the pull, install-config, provider, platform and extra secrets of the cluster pool that no
+// sibling references, including the copies derived from its install-config template, and returns the names of the deleted secrets. The cluster pool itself may be in siblings.
+// Every secret is attempted, the errors of those that failed are joined.
+// A secret is shared when a sibling on any platform references it under any type, and is deleted once however many of the
+// cluster pool's refs point at it.
+func (c *SecretCleaner) CleanupForPool(ctx context.Context, cp *hivev1.ClusterPool, siblings []hivev1.ClusterPool) ([]string, error) {
+	log := c.Log
+
+	siblingRefs := map[string]bool{}
+	for _, foundCp := range siblings {
+
+		// Skip if the cluster pool being deleted is the element in the list
+		if cp.Name == foundCp.Name && cp.Namespace == foundCp.Namespace {
+			continue
+		}
+
+		// A sibling ref with stray whitespace still keeps the secret it was meant for
+		for _, name := range getReferencedSecretNames(c.ResolveName, c.ExtraRefPaths, foundCp) {
+			siblingRefs[name] = true
+			siblingRefs[strings.TrimSpace(name)] = true
+		}
+	}
+
+	cpType, providerSecretName := getCPDetails(*cp)
+	extraSecrets := getCPExtraSecrets(*cp)
+
+	foundPullSecret := cp.Spec.PullSecretRef != nil && siblingRefs[cp.Spec.PullSecretRef.Name]
+	foundInstallConfigSecret := cp.Spec.InstallConfigSecretTemplateRef != nil && siblingRefs[cp.Spec.InstallConfigSecretTemplateRef.Name]
+	foundProviderSecret := siblingRefs[providerSecretName]
+	foundExtraSecrets := false
+	for _, secret := range extraSecrets {
+		foundExtraSecrets = foundExtraSecrets || siblingRefs[secret.name]
+	}
+
+	log.V(INFO).Info("Shared secrets found", "installConfig", foundInstallConfigSecret, "pull", foundPullSecret,
+		"provider", foundProviderSecret, "platform", foundExtraSecrets)
+
+	if cpType == CP_TYPE_NONE {
+		log.V(DEBUG).Info("No provider secret", "cpType", CP_TYPE_NONE, "clusterPool", cp.Name)
+	} else {
+		log.V(DEBUG).Info("Provider secret", "cpType", cpType, "providerSecretName", providerSecretName)
+	}
+
+	// The unshared secrets to delete, in order, with the types the cluster pool references each one under
+	var names []string
+	secretTypes := map[string][]string{}
+	add := func(secretType string, name string) {
+		if name == "" || siblingRefs[name] || !c.validSecretName(cp, secretType, name) {
+			return
+		}
+		if _, found := secretTypes[name]; !found {
+			names = append(names, name)
+		}
+		secretTypes[name] = append(secretTypes[name], secretType)
+	}
+	addRef := func(secretType string, refName string) {
+		if refName == "" {
+			return
+		}
+		for _, name := range resolveSecretNames(c.ResolveName, cp, []string{refName}) {
+			add(secretType, name)
+		}
+	}
+
+	if cp.Spec.InstallConfigSecretTemplateRef == nil {
+		log.V(DEBUG).Info("No install-config template configured", "clusterPool", cp.Name)
+	} else {
+		addRef(SECRET_TYPE_INSTALLCONFIG, cp.Spec.InstallConfigSecretTemplateRef.Name)
+
+		derived, err := c.derivedInstallConfigSecrets(ctx, cp)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range derived {
+			if !slices.ContainsFunc(siblings, func(foundCp hivev1.ClusterPool) bool {
+				return foundCp.Name != cp.Name && isDerivedInstallConfig(foundCp, name)
+			}) {
+				add(SECRET_TYPE_INSTALLCONFIG, name)
+			}
+		}
+	}
+
+	if cp.Spec.PullSecretRef == nil {
+		log.V(DEBUG).Info("No pull secret configured", "clusterPool", cp.Name)
+	} else {
+		addRef(SECRET_TYPE_PULL, cp.Spec.PullSecretRef.Name)
+	}
+
+	if cpType != CP_TYPE_NONE && c.KeepProvider {
+		log.V(DEBUG).Info("Keeping provider secret for the deprovisioning cluster deployments", "name", providerSecretName, "clusterPool", cp.Name)
+	} else if cpType != CP_TYPE_NONE {
+		addRef(SECRET_TYPE_PROVIDER, providerSecretName)
+	}
+
+	for _, secret := range extraSecrets {
+		addRef(secret.secretType, secret.name)
+	}
+
+	for _, ref := range getExtraSecretRefs(c.ExtraRefPaths, *cp) {
+		addRef(ref.secretType, ref.name)
+	}
+
+	// Attempt every secret, so one that fails to delete does not leave the others behind
+	var deleted []string
+	var errs []error
+	for _, name := range names {
+		ok, err := c.cleanupSecret(ctx, cp, secretTypes[name], name)
+		if ok {
+			deleted = append(deleted, name)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// CleanupNamespace deletes the secrets matching the label selector in the cluster pool namespace with a single
+// DeleteCollection, for the last cluster pool of the namespace, and returns the names of the deleted secrets.
+// The cluster pool's retained secrets are kept, and its secrets without the label, including the copies derived
+// from its install-config template, are deleted one by one.
+func (c *SecretCleaner) CleanupNamespace(ctx context.Context, cp *hivev1.ClusterPool, labelSelector string) ([]string, error) {
+	var names []string
+	secretTypes := map[string][]string{}
+	add := func(secretType string, name string) {
+		if !c.validSecretName(cp, secretType, name) {
+			return
+		}
+		if _, found := secretTypes[name]; !found {
+			names = append(names, name)
+		}
+		secretTypes[name] = append(secretTypes[name], secretType)
+	}
+	for _, ref := range append(getCPSecretRefs(*cp), getExtraSecretRefs(c.ExtraRefPaths, *cp)...) {
+		for _, name := range resolveSecretNames(c.ResolveName, cp, []string{ref.name}) {
+			add(ref.secretType, name)
+		}
+	}
+
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single list finds both the labeled secrets and the copies derived from the install-config template
+	secrets, err := c.KubeClient.CoreV1().Secrets(cp.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		if isDerivedInstallConfig(*cp, secret.Name) {
+			add(SECRET_TYPE_INSTALLCONFIG, secret.Name)
+		}
+	}
+
+	retained := map[string]bool{}
+	var keep []fields.Selector
+	for _, name := range names {
+		if slices.ContainsFunc(secretTypes[name], func(secretType string) bool { return retainsSecret(cp, secretType) }) {
+			c.Log.V(INFO).Info("Skipped deleting retained secret", "name", name, "clusterPool", cp.Name)
+			retained[name] = true
+			keep = append(keep, fields.OneTermNotEqualSelector("metadata.name", name))
+		}
+	}
+
+	secrets.Items = slices.DeleteFunc(secrets.Items, func(secret corev1.Secret) bool {
+		return retained[secret.Name] || !selector.Matches(labels.Set(secret.Labels))
+	})
+
+	var deleted []string
+	if len(secrets.Items) > 0 {
+		if err := c.KubeClient.CoreV1().Secrets(cp.Namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+			LabelSelector: labelSelector,
+			FieldSelector: fields.AndSelectors(keep...).String(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	labeled := map[string]bool{}
+	var errs []error
+	for _, secret := range secrets.Items {
+		labeled[secret.Name] = true
+		deleted = append(deleted, secret.Name)
+
+		secretType := SECRET_TYPE_LABELED
+		if types, found := secretTypes[secret.Name]; found {
+			secretType = types[0]
+		}
+		c.Log.V(INFO).Info("Deleted secret", "type", secretTypeDescriptions[secretType], "name", secret.Name, "namespace", cp.Namespace)
+		if c.OnDelete != nil {
+			c.OnDelete(cp, secretType, secret.Name)
+		}
+	}
+
+	for _, name := range names {
+		if labeled[name] || retained[name] {
+			continue
+		}
+		ok, err := c.cleanupSecret(ctx, cp, secretTypes[name], name)
+		if ok {
+			deleted = append(deleted, name)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// validSecretName reports whether a secret can be named after the name the cluster pool refers to. A ref no
+// secret can be named after, like one with whitespace from a bad template, is never deleted, rather than guessing
+// which secret it meant.
+func (c *SecretCleaner) validSecretName(cp *hivev1.ClusterPool, secretType string, name string) bool {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		c.Log.V(WARN).Info("Skipped deleting secret, the ref is not a valid secret name", "type", secretTypeDescriptions[secretType],
+			"name", name, "clusterPool", cp.Name, "errors", errs)
+		return false
+	}
+	return true
+}
+
+// derivedInstallConfigSecrets returns the secrets of the cluster pool namespace that Hive derived from the
+// cluster pool's install-config template, see isDerivedInstallConfig
+func (c *SecretCleaner) derivedInstallConfigSecrets(ctx context.Context, cp *hivev1.ClusterPool) ([]string, error) {
+	if cp.Spec.InstallConfigSecretTemplateRef == nil || cp.Spec.InstallConfigSecretTemplateRef.Name == "" {
+		return nil, nil
+	}
+
+	secrets, err := c.KubeClient.CoreV1().Secrets(cp.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var derived []string
+	for _, secret := range secrets.Items {
+		if isDerivedInstallConfig(*cp, secret.Name) {
+			derived = append(derived, secret.Name)
+		}
+	}
+	return derived, nil
+}
+
+// isDerivedInstallConfig reports whether the secret name is a copy of the cluster pool's install-config template,
+// named "<cluster pool>-<template>" with an optional "-<suffix>"
+func isDerivedInstallConfig(cp hivev1.ClusterPool, name string) bool {
+	if cp.Spec.InstallConfigSecretTemplateRef == nil || cp.Spec.InstallConfigSecretTemplateRef.Name == "" {
+		return false
+	}
+	prefix := cp.Name + "-" + cp.Spec.InstallConfigSecretTemplateRef.Name
+	return name == prefix || strings.HasPrefix(name, prefix+"-")
+}
+
+// isManagedSecret reports whether the secret carries the label key or the MANAGED annotation
+func isManagedSecret(secret *corev1.Secret, labelKey string) bool {
+	if _, found := secret.Labels[labelKey]; found {
+		return true
+	}
+	return annotationEnabled(secret.Annotations, MANAGED)
+}
+
+// retainsSecret reports whether the cluster pool's RETAIN_SECRETS annotation lists the secret type.
+// Certificates secrets are provider secrets, so they are retained with "provider".
+func retainsSecret(cp *hivev1.ClusterPool, secretType string) bool {
+	if secretType == SECRET_TYPE_CERTIFICATES {
+		secretType = SECRET_TYPE_PROVIDER
+	}
+	for _, retained := range strings.Split(cp.Annotations[RETAIN_SECRETS], ",") {
+		if strings.TrimSpace(retained) == secretType {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupSecret deletes a secret that no other cluster pool references, and reports whether it was deleted.
+// The secret is kept when the cluster pool retains any of the types it references the secret under, or with
+// ProviderOnly, references it under a type other than provider or certificates, or with ManagedLabel, the secret
+// is not labeled as managed.
+func (c *SecretCleaner) cleanupSecret(ctx context.Context, cp *hivev1.ClusterPool, secretTypes []string, name string) (bool, error) {
+	secretType := secretTypes[0]
+	for _, t := range secretTypes {
+		if retainsSecret(cp, t) {
+			c.Log.V(INFO).Info("Skipped deleting retained secret", "type", secretTypeDescriptions[t], "name", name, "clusterPool", cp.Name)
+			return false, nil
+		}
+		if c.ProviderOnly && t != SECRET_TYPE_PROVIDER && t != SECRET_TYPE_CERTIFICATES {
+			c.Log.V(DEBUG).Info("Skipped deleting secret outside the cleanup scope", "type", secretTypeDescriptions[t], "name", name, "clusterPool", cp.Name)
+			return false, nil
+		}
+	}
+
+	// Keep going if the secret is already gone, but fail on any other error reading it
+	secret, err := c.KubeClient.CoreV1().Secrets(cp.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			c.Log.V(WARN).Info("Referenced secret was already gone", "type", secretTypeDescriptions[secretType], "name", name,
+				"namespace", cp.Namespace, "clusterPool", cp.Name)
+			return false, nil
+		}
+		return false, &ErrSecretReadFailed{Secret: types.NamespacedName{Namespace: cp.Namespace, Name: name}, Err: err}
+	}
+
+	if c.ManagedLabel != "" && !isManagedSecret(secret, c.ManagedLabel) {
+		c.Log.V(WARN).Info("Skipped deleting secret, it is not labeled as managed", "type", secretTypeDescriptions[secretType], "name", name,
+			"namespace", cp.Namespace, "label", c.ManagedLabel, "annotation", MANAGED)
+		return false, nil
+	}
+
+	if err := deleteWithRetries(c.Retries, func() error {
+		return c.KubeClient.CoreV1().Secrets(cp.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	}); err != nil {
+		return false, err
+	}
+	c.Log.V(INFO).Info("Deleted secret", "type", secretTypeDescriptions[secretType], "name", name, "namespace", cp.Namespace)
+	if c.OnDelete != nil {
+		c.OnDelete(cp, secretType, name)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,492 @@
+package clusterpools
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func getSecretCleaner(namespace string, names ...string) *SecretCleaner {
+	kubeClient := kubefake.NewSimpleClientset()
+	for _, name := range names {
+		kubeClient.CoreV1().Secrets(namespace).Create(context.Background(), getSecret(namespace, name), v1.CreateOptions{})
+	}
+
+	return &SecretCleaner{
+		KubeClient: kubeClient,
+		Log:        ctrl.Log.WithName("controllers").WithName("SecretCleaner"),
+	}
+}
+
+func TestSecretCleanerCleanupForPool(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03", "secret04")
+
+	var onDelete []string
+	c.OnDelete = func(cp *hivev1.ClusterPool, secretType string, name string) {
+		onDelete = append(onDelete, secretType+"/"+name)
+	}
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere")
+
+	deleted, err := c.CleanupForPool(ctx, cp, []hivev1.ClusterPool{*cp})
+
+	assert.Nil(t, err, "nil, when the secrets were cleaned up")
+	assert.Equal(t, []string{"secret02", "secret01", "secret03", "secret04"}, deleted, "all secrets of a lone pool are deleted")
+	assert.Equal(t, []string{"installconfig/secret02", "pull/secret01", "provider/secret03", "certificates/secret04"}, onDelete,
+		"OnDelete is called for each deleted secret")
+
+	secrets, _ := c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).List(ctx, v1.ListOptions{})
+	assert.Empty(t, secrets.Items, "no secrets are left")
+}
+
+func TestSecretCleanerCleanupForPoolSiblings(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+	sibling.Spec.PullSecretRef.Name = "secret11"
+
+	deleted, err := c.CleanupForPool(ctx, cp, []hivev1.ClusterPool{*sibling})
+
+	assert.Nil(t, err, "nil, when the secrets were cleaned up")
+	assert.Equal(t, []string{"secret01"}, deleted, "only the pull secret is not shared with the sibling")
+}
+
+func TestSecretCleanerCleanupForPoolInvalidRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, name := range []string{"secret01 ", " secret01", "secret01\n", "Secret_01"} {
+		c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+		var logged []string
+		c.Log = funcr.New(func(prefix, args string) {
+			logged = append(logged, args)
+		}, funcr.Options{})
+
+		cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+		cp.Spec.PullSecretRef.Name = name
+
+		deleted, err := c.CleanupForPool(ctx, cp, nil)
+
+		assert.Nil(t, err, "nil, when the invalid ref "+strconv.Quote(name)+" was skipped")
+		assert.Equal(t, []string{"secret02", "secret03"}, deleted, "the valid refs are still deleted")
+		_, err = c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret01", v1.GetOptions{})
+		assert.Nil(t, err, "the secret the invalid ref "+strconv.Quote(name)+" resembles is kept")
+		assert.True(t, slices.ContainsFunc(logged, func(line string) bool {
+			return strings.Contains(line, "the ref is not a valid secret name")
+		}), "a warning names the invalid ref "+strconv.Quote(name))
+	}
+}
+
+func TestSecretCleanerCleanupForPoolSiblingInvalidRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.PullSecretRef.Name = "secret01 "
+	sibling.Spec.InstallConfigSecretTemplateRef.Name = "secret12"
+	sibling.Spec.Platform.GCP.CredentialsSecretRef.Name = "secret13"
+
+	deleted, err := c.CleanupForPool(ctx, cp, []hivev1.ClusterPool{*sibling})
+
+	assert.Nil(t, err, "nil, when the secrets were cleaned up")
+	assert.Equal(t, []string{"secret02", "secret03"}, deleted, "a sibling ref with whitespace still shares the secret")
+}
+
+// suffixResolver names the secrets of a ref after the ref and the "-prod" suffix
+func suffixResolver(cp *hivev1.ClusterPool, refName string) []string {
+	return []string{refName + "-prod"}
+}
+
+func TestSecretCleanerCleanupForPoolResolveName(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret01-prod", "secret02-prod", "secret03-prod")
+	c.ResolveName = suffixResolver
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.PullSecretRef.Name = "secret11"
+	sibling.Spec.Platform.GCP.CredentialsSecretRef.Name = "secret13"
+
+	deleted, err := c.CleanupForPool(ctx, cp, []hivev1.ClusterPool{*sibling})
+
+	assert.Nil(t, err, "nil, when the resolved secrets were cleaned up")
+	assert.Equal(t, []string{"secret01-prod", "secret03-prod"}, deleted, "the resolved secrets no sibling resolves to are deleted")
+	_, err = c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret02-prod", v1.GetOptions{})
+	assert.Nil(t, err, "the secret the sibling resolves to is kept")
+	_, err = c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret01", v1.GetOptions{})
+	assert.Nil(t, err, "the secret named after the ref is not considered")
+}
+
+func TestSecretCleanerCleanupForPoolMissingSecrets(t *testing.T) {
+
+	c := getSecretCleaner(CP_NAMESPACE)
+
+	deleted, err := c.CleanupForPool(context.Background(), GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), nil)
+
+	assert.Nil(t, err, "nil, when the secrets were already gone")
+	assert.Empty(t, deleted, "missing secrets are not reported as deleted")
+}
+
+func TestSecretCleanerCleanupForPoolDeleteError(t *testing.T) {
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+	c.KubeClient.(*kubefake.Clientset).PrependReactor("delete", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.(clienttesting.DeleteAction).GetName() == "secret01" {
+			return true, nil, errors.New("delete failed")
+		}
+		return false, nil, nil
+	})
+
+	deleted, err := c.CleanupForPool(context.Background(), GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), nil)
+
+	assert.NotNil(t, err, "not nil, when a secret delete failed")
+	assert.Contains(t, err.Error(), "delete failed", "the delete error is returned")
+	assert.Equal(t, []string{"secret02", "secret03"}, deleted, "the secrets after the failure are still deleted")
+}
+
+func TestSecretCleanerCleanupForPoolSameSecret(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Spec.InstallConfigSecretTemplateRef.Name = "secret01"
+	cp.Spec.Platform.AWS.CredentialsSecretRef.Name = "secret01"
+
+	deleted, err := c.CleanupForPool(ctx, cp, nil)
+
+	assert.Nil(t, err, "nil, when the secret was cleaned up")
+	assert.Equal(t, []string{"secret01"}, deleted, "the secret is deleted once")
+
+	deletes := 0
+	for _, action := range c.KubeClient.(*kubefake.Clientset).Actions() {
+		if action.GetVerb() == "delete" && action.GetResource().Resource == "secrets" {
+			deletes++
+		}
+	}
+	assert.Equal(t, 1, deletes, "exactly one delete is issued")
+}
+
+func TestSecretCleanerCleanupForPoolSharedUnderOtherType(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	sibling := GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME+"02", "aws")
+	sibling.Spec.PullSecretRef = &corev1.LocalObjectReference{Name: "secret03"}
+
+	deleted, err := c.CleanupForPool(ctx, cp, []hivev1.ClusterPool{*sibling})
+
+	assert.Nil(t, err, "nil, when the secrets were cleaned up")
+	assert.Equal(t, []string{"secret02", "secret01"}, deleted, "the provider secret used as a sibling's pull secret is kept")
+}
+
+func TestSecretCleanerCleanupForPoolRetainedUnderOneType(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Spec.Platform.AWS.CredentialsSecretRef.Name = "secret01"
+	cp.Spec.InstallConfigSecretTemplateRef = nil
+	cp.Annotations = map[string]string{RETAIN_SECRETS: SECRET_TYPE_PROVIDER}
+
+	deleted, err := c.CleanupForPool(ctx, cp, nil)
+
+	assert.Nil(t, err, "nil, when the secret was retained")
+	assert.Empty(t, deleted, "a secret retained under one of its types is kept")
+}
+
+// secretRefTypes names the secret of each type GetClusterPool references
+var secretRefTypes = map[string]string{
+	SECRET_TYPE_PULL:          "secret01",
+	SECRET_TYPE_INSTALLCONFIG: "secret02",
+	SECRET_TYPE_PROVIDER:      "secret03",
+}
+
+func TestSecretCleanerCleanupForPoolSecretGone(t *testing.T) {
+
+	ctx := context.Background()
+
+	for secretType, missing := range secretRefTypes {
+		c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+		c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Delete(ctx, missing, v1.DeleteOptions{})
+
+		var logged []string
+		c.Log = funcr.New(func(prefix, args string) {
+			logged = append(logged, args)
+		}, funcr.Options{})
+
+		deleted, err := c.CleanupForPool(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), nil)
+
+		assert.Nil(t, err, "nil, when the "+secretType+" secret was already gone")
+		assert.NotContains(t, deleted, missing, "the missing "+secretType+" secret is not reported as deleted")
+		assert.Len(t, deleted, 2, "the other secrets are still deleted, missing "+secretType)
+		assert.True(t, slices.ContainsFunc(logged, func(line string) bool {
+			return strings.Contains(line, "Referenced secret was already gone") && strings.Contains(line, missing)
+		}), "a warning names the missing "+secretType+" secret")
+	}
+}
+
+func TestSecretCleanerCleanupForPoolGetError(t *testing.T) {
+
+	ctx := context.Background()
+
+	for secretType, failing := range secretRefTypes {
+		c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+		c.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.(clienttesting.GetAction).GetName() == failing {
+				return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, failing, errors.New("denied"))
+			}
+			return false, nil, nil
+		})
+
+		_, err := c.CleanupForPool(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), nil)
+
+		assert.NotNil(t, err, "not nil, when reading the "+secretType+" secret failed")
+		assert.True(t, k8serrors.IsForbidden(err), "the read error is returned for the "+secretType+" secret")
+		for _, action := range c.KubeClient.(*kubefake.Clientset).Actions() {
+			if action.GetVerb() == "delete" {
+				assert.NotEqual(t, failing, action.(clienttesting.DeleteAction).GetName(), "the unreadable "+secretType+" secret is not deleted")
+			}
+		}
+	}
+}
+
+// addDeleteCollectionReactor serves secret DeleteCollection calls from the object tracker, which the fake
+// clientset does not do on its own
+func addDeleteCollectionReactor(kubeClient *kubefake.Clientset) {
+	kubeClient.PrependReactor("delete-collection", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		restrictions := action.(clienttesting.DeleteCollectionAction).GetListRestrictions()
+		gvr := corev1.SchemeGroupVersion.WithResource("secrets")
+		list, err := kubeClient.Tracker().List(gvr, corev1.SchemeGroupVersion.WithKind("Secret"), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		for _, secret := range list.(*corev1.SecretList).Items {
+			if restrictions.Labels.Matches(labels.Set(secret.Labels)) &&
+				restrictions.Fields.Matches(fields.Set{"metadata.name": secret.Name}) {
+				if err := kubeClient.Tracker().Delete(gvr, secret.Namespace, secret.Name); err != nil {
+					return true, nil, err
+				}
+			}
+		}
+		return true, nil, nil
+	})
+}
+
+// labelSecrets adds the namespace label to the named secrets
+func labelSecrets(c *SecretCleaner, names ...string) {
+	for _, name := range names {
+		secret, _ := c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(context.Background(), name, v1.GetOptions{})
+		secret.Labels = map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}
+		c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Update(context.Background(), secret, v1.UpdateOptions{})
+	}
+}
+
+func TestSecretCleanerCleanupNamespace(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03", "orphan", "unrelated")
+	addDeleteCollectionReactor(c.KubeClient.(*kubefake.Clientset))
+	labelSecrets(c, "secret01", "secret02", "orphan")
+
+	var onDelete []string
+	c.OnDelete = func(cp *hivev1.ClusterPool, secretType string, name string) {
+		onDelete = append(onDelete, secretType+"/"+name)
+	}
+
+	deleted, err := c.CleanupNamespace(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), LABEL_NAMESPACE+"="+CLUSTERPOOLS)
+
+	assert.Nil(t, err, "nil, when the namespace secrets were cleaned up")
+	assert.ElementsMatch(t, []string{"secret01", "secret02", "orphan", "secret03"}, deleted,
+		"labeled secrets and the unlabeled secrets of the pool are deleted")
+	assert.Contains(t, onDelete, SECRET_TYPE_LABELED+"/orphan", "an unreferenced labeled secret is reported as labeled")
+	assert.Contains(t, onDelete, SECRET_TYPE_PULL+"/secret01", "a referenced labeled secret is reported under its type")
+
+	secrets, _ := c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).List(ctx, v1.ListOptions{})
+	assert.Len(t, secrets.Items, 1, "only the unrelated secret is left")
+	assert.Equal(t, "unrelated", secrets.Items[0].Name, "an unlabeled secret the pool does not reference is kept")
+}
+
+func TestSecretCleanerCleanupNamespaceRetained(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03")
+	addDeleteCollectionReactor(c.KubeClient.(*kubefake.Clientset))
+	labelSecrets(c, "secret01", "secret02", "secret03")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{RETAIN_SECRETS: SECRET_TYPE_PULL}
+
+	deleted, err := c.CleanupNamespace(ctx, cp, LABEL_NAMESPACE+"="+CLUSTERPOOLS)
+
+	assert.Nil(t, err, "nil, when the namespace secrets were cleaned up")
+	assert.ElementsMatch(t, []string{"secret02", "secret03"}, deleted, "the retained secret is not deleted")
+
+	_, err = c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret01", v1.GetOptions{})
+	assert.Nil(t, err, "the retained labeled secret survives the DeleteCollection")
+}
+
+func TestSecretCleanerCleanupNamespaceApiCalls(t *testing.T) {
+
+	ctx := context.Background()
+	names := []string{"secret01", "secret02", "secret03"}
+
+	perSecret := getSecretCleaner(CP_NAMESPACE, names...)
+	labelSecrets(perSecret, names...)
+	perSecret.KubeClient.(*kubefake.Clientset).ClearActions()
+	_, err := perSecret.CleanupForPool(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), nil)
+	assert.Nil(t, err, "nil, when the secrets were deleted one by one")
+
+	batch := getSecretCleaner(CP_NAMESPACE, names...)
+	addDeleteCollectionReactor(batch.KubeClient.(*kubefake.Clientset))
+	labelSecrets(batch, names...)
+	batch.KubeClient.(*kubefake.Clientset).ClearActions()
+	_, err = batch.CleanupNamespace(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), LABEL_NAMESPACE+"="+CLUSTERPOOLS)
+	assert.Nil(t, err, "nil, when the secrets were deleted at once")
+
+	assert.Len(t, perSecret.KubeClient.(*kubefake.Clientset).Actions(), 1+2*len(names),
+		"a list of the derived install-config secrets, and a get and a delete per secret")
+	assert.Len(t, batch.KubeClient.(*kubefake.Clientset).Actions(), 2, "a list and a single DeleteCollection")
+}
+
+func TestSecretCleanerCleanupForPoolDerivedInstallConfig(t *testing.T) {
+
+	ctx := context.Background()
+
+	// secret02 is the install-config template, the pool-secret02 secrets are copies derived from it
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03",
+		CP_NAME+"-secret02", CP_NAME+"-secret02-x7k2p", CP_NAME+"02-secret02", "secret02-unrelated")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "gcp")
+	sibling.Spec.PullSecretRef.Name = "secret11"
+	sibling.Spec.Platform.GCP.CredentialsSecretRef.Name = "secret13"
+
+	deleted, err := c.CleanupForPool(ctx, cp, []hivev1.ClusterPool{*cp, *sibling})
+
+	assert.Nil(t, err, "nil, when the secrets were cleaned up")
+	assert.ElementsMatch(t, []string{"secret01", "secret03", CP_NAME + "-secret02", CP_NAME + "-secret02-x7k2p"}, deleted,
+		"the derived install-config secrets are deleted with the pool, the shared template is kept")
+	for _, name := range []string{"secret02", CP_NAME + "02-secret02", "secret02-unrelated"} {
+		_, err = c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, name, v1.GetOptions{})
+		assert.Nil(t, err, "the template, the sibling's derived copy and unrelated secrets are kept: "+name)
+	}
+}
+
+func TestSecretCleanerCleanupForPoolDerivedInstallConfigShared(t *testing.T) {
+
+	ctx := context.Background()
+
+	// The sibling's template is the pool's derived copy, so it stays referenced
+	c := getSecretCleaner(CP_NAMESPACE, "secret02", CP_NAME+"-secret02")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	sibling := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+	sibling.Spec.InstallConfigSecretTemplateRef.Name = CP_NAME + "-secret02"
+
+	deleted, err := c.CleanupForPool(ctx, cp, []hivev1.ClusterPool{*cp, *sibling})
+
+	assert.Nil(t, err, "nil, when the secrets were cleaned up")
+	assert.ElementsMatch(t, []string{"secret02"}, deleted, "a derived copy another pool references is kept")
+}
+
+func TestSecretCleanerCleanupNamespaceResolveName(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01-prod", "secret02-prod", "secret03-prod", "secret01")
+	c.ResolveName = suffixResolver
+	addDeleteCollectionReactor(c.KubeClient.(*kubefake.Clientset))
+	labelSecrets(c, "secret01-prod", "secret02-prod")
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Annotations = map[string]string{RETAIN_SECRETS: SECRET_TYPE_PULL}
+
+	deleted, err := c.CleanupNamespace(ctx, cp, LABEL_NAMESPACE+"="+CLUSTERPOOLS)
+
+	assert.Nil(t, err, "nil, when the namespace secrets were cleaned up")
+	assert.ElementsMatch(t, []string{"secret02-prod", "secret03-prod"}, deleted,
+		"the resolved secrets are deleted, the unlabeled one one by one")
+	_, err = c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret01-prod", v1.GetOptions{})
+	assert.Nil(t, err, "the retained resolved secret survives the DeleteCollection")
+	_, err = c.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Get(ctx, "secret01", v1.GetOptions{})
+	assert.Nil(t, err, "the secret named after the ref is not considered")
+}
+
+func TestSecretCleanerCleanupNamespaceInvalidRef(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret03")
+	addDeleteCollectionReactor(c.KubeClient.(*kubefake.Clientset))
+	labelSecrets(c, "secret01")
+
+	var gets []string
+	c.KubeClient.(*kubefake.Clientset).PrependReactor("get", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		gets = append(gets, action.(clienttesting.GetAction).GetName())
+		return false, nil, nil
+	})
+
+	cp := GetClusterPool(CP_NAMESPACE, CP_NAME, "aws")
+	cp.Spec.InstallConfigSecretTemplateRef.Name = "secret02 "
+
+	deleted, err := c.CleanupNamespace(ctx, cp, LABEL_NAMESPACE+"="+CLUSTERPOOLS)
+
+	assert.Nil(t, err, "nil, when the namespace secrets were cleaned up")
+	assert.ElementsMatch(t, []string{"secret01", "secret03"}, deleted, "the secrets with valid refs are deleted")
+	assert.NotContains(t, gets, "secret02 ", "a ref that is not a valid secret name is never read or deleted")
+}
+
+func TestSecretCleanerCleanupNamespaceDerivedInstallConfig(t *testing.T) {
+
+	ctx := context.Background()
+
+	c := getSecretCleaner(CP_NAMESPACE, "secret01", "secret02", "secret03", CP_NAME+"-secret02")
+	addDeleteCollectionReactor(c.KubeClient.(*kubefake.Clientset))
+	labelSecrets(c, "secret01", "secret02", "secret03")
+
+	deleted, err := c.CleanupNamespace(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), LABEL_NAMESPACE+"="+CLUSTERPOOLS)
+
+	assert.Nil(t, err, "nil, when the namespace secrets were cleaned up")
+	assert.ElementsMatch(t, []string{"secret01", "secret02", "secret03", CP_NAME + "-secret02"}, deleted,
+		"the unlabeled derived install-config secret is deleted one by one")
+}
@@ -0,0 +1,61 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"slices"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// secretDeleteFilter only passes secret deletions, a deleted secret may turn the MissingSecret condition of
+// the cluster pools referencing it
+func secretDeleteFilter() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return true },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+	}
+}
+
+// mapSecretToPools returns the requests of the watched cluster pools that own the secret, or reference it
+// from its namespace. The pools are reconciled again, also when already reconciled at their generation.
+func mapSecretToPools(ctx context.Context, r *ClusterPoolsReconciler, secret client.Object) []ctrl.Request {
+	keys := map[types.NamespacedName]bool{}
+	for _, owner := range secret.GetOwnerReferences() {
+		if owner.Kind == "ClusterPool" && owner.APIVersion == hivev1.SchemeGroupVersion.String() {
+			keys[types.NamespacedName{Namespace: secret.GetNamespace(), Name: owner.Name}] = true
+		}
+	}
+
+	var cps hivev1.ClusterPoolList
+	if err := r.List(ctx, &cps, &client.ListOptions{Namespace: secret.GetNamespace()}); err != nil {
+		r.Log.V(WARN).Info("Failed to list the cluster pools referencing a deleted secret", "name", secret.GetName(),
+			"namespace", secret.GetNamespace(), "error", err.Error())
+	}
+	for i := range cps.Items {
+		cp := &cps.Items[i]
+		if slices.Contains(getReferencedSecretNames(r.SecretNameResolver, r.ExtraSecretRefPaths, *cp), secret.GetName()) {
+			keys[client.ObjectKeyFromObject(cp)] = true
+		}
+	}
+
+	var requests []ctrl.Request
+	for _, cp := range cps.Items {
+		key := client.ObjectKeyFromObject(&cp)
+		if !keys[key] || !watchesPool(r, &cp) {
+			continue
+		}
+		r.reconciledGenerations.Delete(key)
+		requests = append(requests, ctrl.Request{NamespacedName: key})
+		r.Log.V(DEBUG).Info("Secret deleted, reconciling its cluster pool", "name", secret.GetName(), "namespace", cp.Namespace, "clusterPool", cp.Name)
+	}
+	return requests
+}
@@ -0,0 +1,90 @@
+package clusterpools
+
+import (
+	"context"
+	"testing"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func getSecretMetadata(namespace string, name string) *v1.PartialObjectMetadata {
+	return &v1.PartialObjectMetadata{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace}}
+}
+
+func TestMapSecretToPools(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+
+	cp2 := GetClusterPool(CP_NAMESPACE, CP_NAME+"02", "aws")
+	cp2.Spec.Platform.AWS.CredentialsSecretRef.Name = "secret13"
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+	cpr.Client.Create(ctx, cp2, &client.CreateOptions{})
+	cpr.Client.Create(ctx, GetClusterPool("other-namespace", CP_NAME, "aws"), &client.CreateOptions{})
+
+	requests := mapSecretToPools(ctx, cpr, getSecretMetadata(CP_NAMESPACE, "secret03"))
+	assert.Equal(t, []ctrl.Request{getRequest()}, requests, "the pool referencing the deleted secret in its namespace is reconciled")
+
+	requests = mapSecretToPools(ctx, cpr, getSecretMetadata(CP_NAMESPACE, "secret01"))
+	assert.Len(t, requests, 2, "every pool referencing a shared secret is reconciled")
+
+	requests = mapSecretToPools(ctx, cpr, getSecretMetadata(CP_NAMESPACE, "unrelated"))
+	assert.Empty(t, requests, "no pool is reconciled for a secret no pool references")
+}
+
+func TestMapSecretToPoolsOwner(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+
+	secret := getSecretMetadata(CP_NAMESPACE, "renamed")
+	secret.OwnerReferences = []v1.OwnerReference{{APIVersion: hivev1.SchemeGroupVersion.String(), Kind: "ClusterPool", Name: CP_NAME}}
+
+	requests := mapSecretToPools(ctx, cpr, secret)
+	assert.Equal(t, []ctrl.Request{getRequest()}, requests, "the pool owning the deleted secret is reconciled")
+}
+
+func TestMapSecretToPoolsForgetsGeneration(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+	cpr.reconciledGenerations.Store(getNamespaceName(CP_NAMESPACE, CP_NAME), int64(1))
+
+	mapSecretToPools(ctx, cpr, getSecretMetadata(CP_NAMESPACE, "secret03"))
+
+	_, found := cpr.reconciledGenerations.Load(getNamespaceName(CP_NAMESPACE, CP_NAME))
+	assert.False(t, found, "the pool is checked again, although its generation did not change")
+}
+
+func TestMapSecretToPoolsUnwatched(t *testing.T) {
+
+	ctx := context.Background()
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.WatchLabelSelector = labels.SelectorFromSet(labels.Set{"team": "a"})
+	cpr.Client.Create(ctx, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), &client.CreateOptions{})
+
+	requests := mapSecretToPools(ctx, cpr, getSecretMetadata(CP_NAMESPACE, "secret03"))
+	assert.Empty(t, requests, "a pool outside the watch label selector is not reconciled")
+}
+
+func TestSecretDeleteFilter(t *testing.T) {
+
+	filter := secretDeleteFilter()
+	secret := getSecretMetadata(CP_NAMESPACE, "secret03")
+
+	assert.True(t, filter.Delete(event.DeleteEvent{Object: secret}), "secret deletions are passed")
+	assert.False(t, filter.Create(event.CreateEvent{Object: secret}), "secret creations are dropped")
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: secret}), "secret updates are dropped")
+}
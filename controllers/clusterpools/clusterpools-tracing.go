@@ -0,0 +1,32 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// getTracer returns the Tracer of the reconciler, a no-op tracer unless one is set
+func getTracer(r *ClusterPoolsReconciler) trace.Tracer {
+	if r.Tracer == nil {
+		return noop.NewTracerProvider().Tracer("")
+	}
+	return r.Tracer
+}
+
+// poolSpanAttributes are the attributes of the spans of a cluster pool
+func poolSpanAttributes(namespace string, name string) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.String("clusterPool", name), attribute.String("namespace", namespace))
+}
+
+// endSpan records the error, if any, on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,64 @@
+package clusterpools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func getSpanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestReconcileClusterPoolTracing(t *testing.T) {
+
+	ctx := context.Background()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(ctx)
+
+	cpr := GetClusterPoolsReconciler()
+	cpr.Tracer = provider.Tracer("clusterpools")
+
+	createDeletingClusterPool(ctx, cpr, GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+	seedSecrets(ctx, cpr, CP_NAMESPACE, "secret01", "secret02", "secret03")
+
+	_, err := cpr.Reconcile(ctx, getRequest())
+	assert.Nil(t, err, "nil, when clusterPool delete reconcile successful")
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2, "a span for the reconcile and one for the cleanup") {
+		return
+	}
+
+	// Spans are exported as they end, the cleanup within the reconcile first
+	cleanup, reconcile := spans[0], spans[1]
+	assert.Equal(t, "deleteResources", cleanup.Name)
+	assert.Equal(t, "Reconcile", reconcile.Name)
+	assert.Equal(t, reconcile.SpanContext.SpanID(), cleanup.Parent.SpanID(), "the cleanup span is a child of the reconcile span")
+
+	for _, span := range spans {
+		attributes := getSpanAttributes(span)
+		assert.Equal(t, CP_NAME, attributes["clusterPool"].AsString(), "the span names the cluster pool")
+		assert.Equal(t, CP_NAMESPACE, attributes["namespace"].AsString(), "the span names the namespace")
+	}
+	assert.ElementsMatch(t, []string{"secret/secret01", "secret/secret02", "secret/secret03"}, getSpanAttributes(cleanup)["deleted"].AsStringSlice(),
+		"the cleanup span lists the deleted resources")
+}
+
+func TestGetTracerNoop(t *testing.T) {
+
+	cpr := GetClusterPoolsReconciler()
+
+	_, span := getTracer(cpr).Start(context.Background(), "Reconcile")
+	assert.False(t, span.SpanContext().IsValid(), "no span is recorded without a Tracer")
+}
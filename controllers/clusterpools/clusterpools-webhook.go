@@ -0,0 +1,127 @@
+// Copyright Contributors to the Open Cluster Management project.
+
+package clusterpools
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const VALIDATE_CLUSTERPOOL_PATH = "/validate-hive-openshift-io-v1-clusterpool"
+const VALIDATE_NAMESPACE_PATH = "/validate-v1-namespace"
+
+// ClusterPoolValidator rejects new cluster pools that reference secrets missing from their namespace
+type ClusterPoolValidator struct {
+	KubeClient kubernetes.Interface
+	Log        logr.Logger
+	Decoder    admission.Decoder
+}
+
+func (v *ClusterPoolValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	var cp hivev1.ClusterPool
+	if err := v.Decoder.Decode(req, &cp); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var missing []string
+	for _, name := range getSecretRefNames(cp) {
+		if _, err := v.KubeClient.CoreV1().Secrets(req.Namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+			if errors.IsNotFound(err) {
+				missing = append(missing, name)
+				continue
+			}
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+
+	if len(missing) > 0 {
+		v.Log.V(INFO).Info("Denied cluster pool", "name", cp.Name, "namespace", req.Namespace, "missing", missing)
+		return admission.Denied("Cluster pool references secrets that do not exist in namespace " + req.Namespace + ": " +
+			strings.Join(missing, ", "))
+	}
+
+	return admission.Allowed("")
+}
+
+// NamespaceValidator rejects removing the namespace label, or changing its value, while the namespace still
+// holds cluster pools, as the namespace would then never be cleaned up
+type NamespaceValidator struct {
+	Client     client.Client
+	Log        logr.Logger
+	Decoder    admission.Decoder
+	LabelKey   string
+	LabelValue string
+}
+
+func (v *NamespaceValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+
+	if req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	var ns, oldNs corev1.Namespace
+	if err := v.Decoder.Decode(req, &ns); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := v.Decoder.DecodeRaw(req.OldObject, &oldNs); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if oldNs.Labels[v.LabelKey] != v.LabelValue || ns.Labels[v.LabelKey] == v.LabelValue {
+		return admission.Allowed("")
+	}
+
+	var cps hivev1.ClusterPoolList
+	if err := v.Client.List(ctx, &cps, client.InNamespace(ns.Name)); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(cps.Items) > 0 {
+		v.Log.V(INFO).Info("Denied removing the namespace label", "namespace", ns.Name, "label", v.LabelKey+"="+v.LabelValue,
+			"clusterPools", len(cps.Items))
+		return admission.Denied("Namespace " + ns.Name + " still holds " + strconv.Itoa(len(cps.Items)) +
+			" cluster pools, the " + v.LabelKey + "=" + v.LabelValue + " label cannot be removed")
+	}
+
+	return admission.Allowed("")
+}
+
+// SetupWebhookWithManager registers the ClusterPoolValidator and NamespaceValidator with the manager's webhook server
+func (r *ClusterPoolsReconciler) SetupWebhookWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(VALIDATE_CLUSTERPOOL_PATH, &webhook.Admission{
+		Handler: &ClusterPoolValidator{
+			KubeClient: r.KubeClient,
+			Log:        r.Log.WithName("ClusterPoolValidator"),
+			Decoder:    admission.NewDecoder(mgr.GetScheme()),
+		},
+	})
+
+	labelKey, labelValue := getNamespaceLabel(r)
+	mgr.GetWebhookServer().Register(VALIDATE_NAMESPACE_PATH, &webhook.Admission{
+		Handler: &NamespaceValidator{
+			Client:     mgr.GetClient(),
+			Log:        r.Log.WithName("NamespaceValidator"),
+			Decoder:    admission.NewDecoder(mgr.GetScheme()),
+			LabelKey:   labelKey,
+			LabelValue: labelValue,
+		},
+	})
+}
@@ -0,0 +1,148 @@
+package clusterpools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func getClusterPoolValidator() *ClusterPoolValidator {
+	return &ClusterPoolValidator{
+		KubeClient: kubefake.NewSimpleClientset(),
+		Log:        ctrl.Log.WithName("webhooks").WithName("ClusterPoolValidator"),
+		Decoder:    admission.NewDecoder(s),
+	}
+}
+
+func getAdmissionRequest(cp *hivev1.ClusterPool, operation admissionv1.Operation) admission.Request {
+	raw, _ := json.Marshal(cp)
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: operation,
+			Namespace: cp.Namespace,
+			Name:      cp.Name,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func TestClusterPoolValidatorAllowed(t *testing.T) {
+
+	ctx := context.Background()
+
+	v := getClusterPoolValidator()
+	for _, name := range []string{"secret01", "secret02", "secret03"} {
+		v.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, name), v1.CreateOptions{})
+	}
+
+	response := v.Handle(ctx, getAdmissionRequest(GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), admissionv1.Create))
+
+	assert.True(t, response.Allowed, "cluster pool with existing secrets is allowed")
+}
+
+func TestClusterPoolValidatorDenied(t *testing.T) {
+
+	ctx := context.Background()
+
+	v := getClusterPoolValidator()
+	v.KubeClient.CoreV1().Secrets(CP_NAMESPACE).Create(ctx, getSecret(CP_NAMESPACE, "secret01"), v1.CreateOptions{})
+
+	response := v.Handle(ctx, getAdmissionRequest(GetClusterPool(CP_NAMESPACE, CP_NAME, "vsphere"), admissionv1.Create))
+
+	assert.False(t, response.Allowed, "cluster pool with missing secrets is denied")
+	assert.Contains(t, response.Result.Message, "secret02, secret03, secret04", "the missing secrets are listed")
+}
+
+func TestClusterPoolValidatorNilRefs(t *testing.T) {
+
+	v := getClusterPoolValidator()
+
+	response := v.Handle(context.Background(), getAdmissionRequest(GetClusterPoolNoRefs(CP_NAMESPACE, CP_NAME, "aws"), admissionv1.Create))
+
+	assert.True(t, response.Allowed, "cluster pool without secret refs is allowed")
+}
+
+func TestClusterPoolValidatorUpdate(t *testing.T) {
+
+	v := getClusterPoolValidator()
+
+	response := v.Handle(context.Background(), getAdmissionRequest(GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"), admissionv1.Update))
+
+	assert.True(t, response.Allowed, "updates are not validated")
+}
+
+func getNamespaceValidator(objs ...client.Object) *NamespaceValidator {
+	return &NamespaceValidator{
+		Client:     clientfake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build(),
+		Log:        ctrl.Log.WithName("webhooks").WithName("NamespaceValidator"),
+		Decoder:    admission.NewDecoder(s),
+		LabelKey:   LABEL_NAMESPACE,
+		LabelValue: CLUSTERPOOLS,
+	}
+}
+
+func getNamespaceUpdateRequest(oldNs *corev1.Namespace, ns *corev1.Namespace) admission.Request {
+	raw, _ := json.Marshal(ns)
+	oldRaw, _ := json.Marshal(oldNs)
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Name:      ns.Name,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		},
+	}
+}
+
+func TestNamespaceValidatorNoPools(t *testing.T) {
+
+	v := getNamespaceValidator()
+
+	response := v.Handle(context.Background(), getNamespaceUpdateRequest(
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), getNamespace(CP_NAMESPACE, nil)))
+
+	assert.True(t, response.Allowed, "the label can be removed from a namespace without cluster pools")
+}
+
+func TestNamespaceValidatorDenied(t *testing.T) {
+
+	v := getNamespaceValidator(GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	response := v.Handle(context.Background(), getNamespaceUpdateRequest(
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}), getNamespace(CP_NAMESPACE, nil)))
+
+	assert.False(t, response.Allowed, "the label cannot be removed while the namespace holds cluster pools")
+	assert.Contains(t, response.Result.Message, "still holds 1 cluster pools", "the cluster pools are counted")
+
+	response = v.Handle(context.Background(), getNamespaceUpdateRequest(
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}),
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: "other-tool"})))
+
+	assert.False(t, response.Allowed, "the label value cannot be changed while the namespace holds cluster pools")
+}
+
+func TestNamespaceValidatorOtherChanges(t *testing.T) {
+
+	v := getNamespaceValidator(GetClusterPool(CP_NAMESPACE, CP_NAME, "aws"))
+
+	response := v.Handle(context.Background(), getNamespaceUpdateRequest(
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS}),
+		getNamespace(CP_NAMESPACE, map[string]string{LABEL_NAMESPACE: CLUSTERPOOLS, "team": "blue"})))
+	assert.True(t, response.Allowed, "other label changes are allowed")
+
+	response = v.Handle(context.Background(), getNamespaceUpdateRequest(getNamespace(CP_NAMESPACE, nil),
+		getNamespace(CP_NAMESPACE, map[string]string{"team": "blue"})))
+	assert.True(t, response.Allowed, "namespaces without the label are not validated")
+}